@@ -0,0 +1,179 @@
+package go_socketio_parser
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+// DecodeArgs stores packet arguments in the values pointed to by targets, in
+// order, as json.Unmarshal does. Nil targets skip the argument at their
+// position and arguments without target are ignored. Buffer values in targets
+// get the binary attachments referenced by their placeholders.
+func (p *Packet) DecodeArgs(targets ...interface{}) error {
+	attachments := map[uint64][]byte{}
+	if err := collectBuffer(reflect.ValueOf(p.Data), attachments); err != nil {
+		return err
+	}
+
+	for idx, target := range targets {
+		if idx >= len(p.Data) {
+			break
+		}
+		if target == nil {
+			continue
+		}
+
+		v := reflect.ValueOf(target)
+		if v.Kind() != reflect.Ptr || v.IsNil() {
+			return errors.New("non-pointer argument target")
+		}
+
+		raw, ok := p.Data[idx].(json.RawMessage)
+		if !ok {
+			var err error
+			raw, err = json.Marshal(p.Data[idx])
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := json.Unmarshal(raw, target); err != nil {
+			return err
+		}
+
+		if err := fillBuffer(v, attachments); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DecodePayload stores CONNECT or CONNECT_ERROR packet payload in the value
+// pointed to by v, as json.Unmarshal does.
+func (p *Packet) DecodePayload(v interface{}) error {
+	if p.Payload == nil {
+		return errors.New("empty packet payload")
+	}
+
+	raw, ok := p.Payload.(json.RawMessage)
+	if !ok {
+		var err error
+		raw, err = json.Marshal(p.Payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	return json.Unmarshal(raw, v)
+}
+
+// collectBuffer stores data of the binary buffers found in v by their number.
+func collectBuffer(v reflect.Value, attachments map[uint64][]byte) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == bufferType {
+			buffer := v.Interface().(Buffer)
+			if buffer.IsBinary {
+				attachments[buffer.Num] = buffer.Data
+			}
+
+			return nil
+		}
+
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" {
+				continue
+			}
+
+			if err := collectBuffer(v.Field(i), attachments); err != nil {
+				return err
+			}
+		}
+	case reflect.Array, reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := collectBuffer(v.Index(i), attachments); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			if err := collectBuffer(v.MapIndex(key), attachments); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// fillBuffer sets data of the binary buffers found in v by their number.
+func fillBuffer(v reflect.Value, attachments map[uint64][]byte) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == bufferType {
+			if !v.CanAddr() {
+				return nil
+			}
+
+			buffer := v.Addr().Interface().(*Buffer)
+			if !buffer.IsBinary {
+				return nil
+			}
+
+			data, ok := attachments[buffer.Num]
+			if !ok {
+				return ErrBufferNum
+			}
+
+			buffer.Data = data
+
+			return nil
+		}
+
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" {
+				continue
+			}
+
+			if err := fillBuffer(v.Field(i), attachments); err != nil {
+				return err
+			}
+		}
+	case reflect.Array, reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := fillBuffer(v.Index(i), attachments); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			// map values are not addressable, so fill the copy and store it back.
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+
+			if err := fillBuffer(elem, attachments); err != nil {
+				return err
+			}
+
+			v.SetMapIndex(key, elem)
+		}
+	}
+
+	return nil
+}
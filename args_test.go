@@ -0,0 +1,153 @@
+package go_socketio_parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type argsPayload struct {
+	Name   string            `json:"name"`
+	Count  int               `json:"count"`
+	Tags   []string          `json:"tags"`
+	File   *Buffer           `json:"file"`
+	Chunks []Buffer          `json:"chunks"`
+	Extra  map[string]Buffer `json:"extra"`
+}
+
+// parserBuffer lets tests declare their own type named Buffer.
+type parserBuffer = Buffer
+
+func TestPacket_DecodeArgs(t *testing.T) {
+	message := Packet{
+		Header: Header{Type: Event},
+		Data: []interface{}{
+			"upload",
+			map[string]interface{}{
+				"name":   "a.bin",
+				"count":  2,
+				"tags":   []interface{}{"x", "y"},
+				"file":   &Buffer{IsBinary: true, Num: 0, Data: []byte{1}},
+				"chunks": []interface{}{&Buffer{IsBinary: true, Num: 1, Data: []byte{2}}},
+				"extra":  map[string]interface{}{"k": &Buffer{IsBinary: true, Num: 2, Data: []byte{3}}},
+			},
+			&Buffer{IsBinary: true, Num: 2, Data: []byte{3}},
+		},
+	}
+
+	var (
+		event   string
+		payload argsPayload
+		buffer  Buffer
+	)
+	require.NoError(t, message.DecodeArgs(&event, &payload, &buffer))
+
+	assert.Equal(t, "upload", event)
+	assert.Equal(t, argsPayload{
+		Name:   "a.bin",
+		Count:  2,
+		Tags:   []string{"x", "y"},
+		File:   &Buffer{IsBinary: true, Num: 0, Data: []byte{1}},
+		Chunks: []Buffer{{IsBinary: true, Num: 1, Data: []byte{2}}},
+		Extra:  map[string]Buffer{"k": {IsBinary: true, Num: 2, Data: []byte{3}}},
+	}, payload)
+	assert.Equal(t, Buffer{IsBinary: true, Num: 2, Data: []byte{3}}, buffer)
+
+	t.Run("skip and extra targets", func(t *testing.T) {
+		message := Packet{Data: []interface{}{"msg", 1}}
+
+		count := -1
+		var missing string
+		require.NoError(t, message.DecodeArgs(nil, &count, &missing))
+
+		assert.Equal(t, 1, count)
+		assert.Empty(t, missing)
+	})
+
+	t.Run("type mismatch", func(t *testing.T) {
+		message := Packet{Data: []interface{}{"msg"}}
+
+		var count int
+		assert.Error(t, message.DecodeArgs(&count))
+	})
+
+	t.Run("non-pointer target", func(t *testing.T) {
+		message := Packet{Data: []interface{}{"msg"}}
+
+		var event string
+		assert.Error(t, message.DecodeArgs(event))
+	})
+
+	t.Run("other type named Buffer", func(t *testing.T) {
+		type Buffer struct {
+			Files []parserBuffer `json:"files"`
+		}
+
+		message := Packet{Data: []interface{}{
+			"upload",
+			Buffer{Files: []parserBuffer{{IsBinary: true, Num: 0, Data: []byte{1}}}},
+		}}
+
+		var files Buffer
+		require.NoError(t, message.DecodeArgs(nil, &files))
+		assert.Equal(t, Buffer{Files: []parserBuffer{{IsBinary: true, Num: 0, Data: []byte{1}}}}, files)
+	})
+
+	t.Run("missing attachment", func(t *testing.T) {
+		message := Packet{Data: []interface{}{map[string]interface{}{"_placeholder": true, "num": 1}}}
+
+		var buffer Buffer
+		assert.Equal(t, ErrBufferNum, message.DecodeArgs(&buffer))
+	})
+}
+
+func TestPacket_DecodePayload(t *testing.T) {
+	t.Run("connect", func(t *testing.T) {
+		var message Packet
+		require.NoError(t, Unmarshal([]byte(`0{"sid":"abc"}`), &message))
+
+		var payload ConnectPayload
+		require.NoError(t, message.DecodePayload(&payload))
+
+		assert.Equal(t, ConnectPayload{SID: "abc"}, payload)
+	})
+
+	t.Run("auth", func(t *testing.T) {
+		var message Packet
+		require.NoError(t, Unmarshal([]byte(`0/admin,{"token":"123","ttl":60}`), &message))
+
+		var auth struct {
+			Token string `json:"token"`
+			TTL   int    `json:"ttl"`
+		}
+		require.NoError(t, message.DecodePayload(&auth))
+
+		assert.Equal(t, "123", auth.Token)
+		assert.Equal(t, 60, auth.TTL)
+	})
+
+	t.Run("connect error", func(t *testing.T) {
+		var message Packet
+		require.NoError(t, Unmarshal([]byte(`4{"message":"Not authorized","data":{"code":401}}`), &message))
+
+		var payload struct {
+			Message string `json:"message"`
+			Data    struct {
+				Code int `json:"code"`
+			} `json:"data"`
+		}
+		require.NoError(t, message.DecodePayload(&payload))
+
+		assert.Equal(t, "Not authorized", payload.Message)
+		assert.Equal(t, 401, payload.Data.Code)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		var message Packet
+		require.NoError(t, Unmarshal([]byte(`0`), &message))
+
+		var payload ConnectPayload
+		assert.Error(t, message.DecodePayload(&payload))
+	})
+}
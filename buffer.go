@@ -1,11 +1,19 @@
 package go_socketio_parser
 
 import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
 	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"unsafe"
 )
 
-// Buffer is a binary buffer handler used in emit args. All buffers will be
-// sent as binary in the transport layer.
+// Buffer is a binary buffer handler used in emit args, by value or by pointer.
+// All buffers will be sent as binary in the transport layer, encoding doesn't
+// change them, so the same buffer can be sent by several packets at once.
 type Buffer struct {
 	IsBinary bool   `json:"_placeholder"`
 	Num      uint64 `json:"num"`
@@ -13,59 +21,482 @@ type Buffer struct {
 	Data []byte `json:"-"`
 }
 
-const structBuffer = "Buffer"
+// MarshalJSON encodes binary buffer as {"_placeholder":true,"num":N} and
+// other buffer as {"base64":true,"data":"<base64>"} keeping its data.
+func (b Buffer) MarshalJSON() ([]byte, error) {
+	if b.IsBinary {
+		ret := make([]byte, 0, len(`{"_placeholder":true,"num":}`)+20)
+		ret = append(ret, `{"_placeholder":true,"num":`...)
+		ret = strconv.AppendUint(ret, b.Num, 10)
 
-func attachBuffer(v reflect.Value, index *uint64) ([][]byte, error) {
-	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
-		v = v.Elem()
+		return append(ret, '}'), nil
 	}
-	var ret [][]byte
 
-	switch v.Kind() {
-	case reflect.Struct:
-		if v.Type().Name() == structBuffer {
-			if !v.CanAddr() {
-				return nil, ErrBufferAddress
+	const prefix = `{"base64":true,"data":"`
+
+	size := len(prefix) + base64.StdEncoding.EncodedLen(len(b.Data))
+	ret := make([]byte, size, size+len(`"}`))
+	copy(ret, prefix)
+	base64.StdEncoding.Encode(ret[len(prefix):], b.Data)
+
+	return append(ret, `"}`...), nil
+}
+
+// UnmarshalJSON decodes both placeholder and base64 forms of buffer.
+func (b *Buffer) UnmarshalJSON(data []byte) error {
+	var v struct {
+		IsBinary bool   `json:"_placeholder"`
+		Num      uint64 `json:"num"`
+		Base64   bool   `json:"base64"`
+		Data     []byte `json:"data"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	b.IsBinary, b.Num = v.IsBinary, v.Num
+	if v.Base64 {
+		b.Data = v.Data
+	}
+
+	return nil
+}
+
+// bufferPlan records where values of a type can hold a Buffer.
+type bufferPlan struct {
+	// buffer is set if values of the type can hold a Buffer at any depth.
+	buffer bool
+	// fields are indexes of the struct fields which can hold a Buffer.
+	fields []int
+}
+
+var (
+	bufferType = reflect.TypeOf(Buffer{})
+
+	// bufferPlans caches *bufferPlan by reflect.Type.
+	bufferPlans sync.Map
+)
+
+// planBuffer returns cached plan of t, so payloads without buffers are not
+// walked on every packet.
+func planBuffer(t reflect.Type) *bufferPlan {
+	if plan, ok := bufferPlans.Load(t); ok {
+		return plan.(*bufferPlan)
+	}
+
+	// types not planned yet, which are reachable from t.
+	var types []reflect.Type
+	seen := map[reflect.Type]bool{}
+	collectPlanTypes(t, seen, &types)
+
+	// recursive types are resolved together: a type holds a Buffer if it is
+	// reachable by any path, so holds are raised until nothing changes.
+	holds := make(map[reflect.Type]bool, len(types))
+	canHold := func(t reflect.Type) bool {
+		if plan, ok := bufferPlans.Load(t); ok {
+			return plan.(*bufferPlan).buffer
+		}
+
+		return holds[t]
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, t := range types {
+			if holds[t] {
+				continue
 			}
 
-			buffer, ok := v.Addr().Interface().(*Buffer)
-			if !ok {
-				return nil, nil
+			hold := t == bufferType || t.Kind() == reflect.Interface
+			for _, child := range planChildren(t) {
+				hold = hold || canHold(child.typ)
 			}
 
-			buffer.Num = *index
-			buffer.IsBinary = true
-			ret = append(ret, buffer.Data)
-			*index++
-		} else {
-			for i := 0; i < v.NumField(); i++ {
-				b, err := attachBuffer(v.Field(i), index)
-				if err != nil {
-					return nil, err
+			if hold {
+				holds[t], changed = true, true
+			}
+		}
+	}
+
+	// plans are stored only when they are final.
+	for _, t := range types {
+		plan := &bufferPlan{buffer: holds[t]}
+		if t.Kind() == reflect.Struct && t != bufferType {
+			for _, child := range planChildren(t) {
+				if canHold(child.typ) {
+					plan.fields = append(plan.fields, child.field)
 				}
+			}
+		}
 
-				ret = append(ret, b...)
+		bufferPlans.LoadOrStore(t, plan)
+	}
+
+	plan, _ := bufferPlans.Load(t)
+
+	return plan.(*bufferPlan)
+}
+
+func collectPlanTypes(t reflect.Type, seen map[reflect.Type]bool, types *[]reflect.Type) {
+	if seen[t] {
+		return
+	}
+	seen[t] = true
+
+	if _, ok := bufferPlans.Load(t); ok {
+		return
+	}
+	*types = append(*types, t)
+
+	for _, child := range planChildren(t) {
+		collectPlanTypes(child.typ, seen, types)
+	}
+}
+
+type planChild struct {
+	typ   reflect.Type
+	field int
+}
+
+// planChildren returns types of values held by values of t. Struct fields are
+// the ones encoded by encoding/json: exported fields and embedded structs,
+// which exported fields are promoted.
+func planChildren(t reflect.Type) []planChild {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Array, reflect.Slice, reflect.Map:
+		return []planChild{{typ: t.Elem()}}
+	case reflect.Struct:
+		if t == bufferType {
+			return nil
+		}
+
+		var children []planChild
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Tag.Get("json") == "-" {
+				continue
 			}
+
+			if f.PkgPath != "" {
+				ft := f.Type
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if !f.Anonymous || ft.Kind() != reflect.Struct {
+					continue
+				}
+			}
+
+			children = append(children, planChild{typ: f.Type, field: i})
+		}
+
+		return children
+	}
+
+	return nil
+}
+
+// structField returns field i of struct v. Embedded unexported structs are
+// read-only for reflect, so their fields are accessed by address the same way
+// as encoding/json encodes them.
+func structField(v reflect.Value, i int) reflect.Value {
+	f := v.Field(i)
+	if f.CanInterface() {
+		return f
+	}
+
+	if !f.CanAddr() {
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(v)
+		f = cp.Field(i)
+	}
+
+	return reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem()
+}
+
+// bufferEncoder replaces buffers found in encoded data by their copies, so data
+// of the caller is never changed. Binary buffers become placeholders numbered by
+// their position and their data is collected to buffers, in base64 mode buffers
+// are inlined.
+type bufferEncoder struct {
+	base64  bool
+	buffers [][]byte
+	err     error
+
+	level int
+	path  map[pathKey]struct{}
+}
+
+// startDetectingCyclesAfter is the nesting of pointers, slices and maps after
+// which cycles are looked for, the same as by encoding/json.
+const startDetectingCyclesAfter = 1000
+
+type pathKey struct {
+	ptr uintptr
+	len int
+}
+
+// enter adds pointer, slice or map v to the current path, it reports false
+// and sets e.err if v is already on the path.
+func (e *bufferEncoder) enter(v reflect.Value) bool {
+	e.level++
+	if e.level <= startDetectingCyclesAfter {
+		return true
+	}
+
+	key := pathKey{ptr: v.Pointer()}
+	if v.Kind() == reflect.Slice {
+		key.len = v.Len()
+	}
+
+	if _, ok := e.path[key]; ok {
+		e.err = &json.UnsupportedValueError{Value: v, Str: "encountered a cycle via " + v.Type().String()}
+		return false
+	}
+
+	if e.path == nil {
+		e.path = make(map[pathKey]struct{})
+	}
+	e.path[key] = struct{}{}
+
+	return true
+}
+
+func (e *bufferEncoder) leave(v reflect.Value) {
+	if e.level > startDetectingCyclesAfter {
+		key := pathKey{ptr: v.Pointer()}
+		if v.Kind() == reflect.Slice {
+			key.len = v.Len()
 		}
+
+		delete(e.path, key)
+	}
+	e.level--
+}
+
+// replace returns v with replaced buffers and reports whether it was changed.
+// Values on the path to a buffer are shallow copies, other values are shared
+// with v. Buffers in maps are numbered in order of sorted keys, the same as
+// they are encoded by encoding/json.
+func (e *bufferEncoder) replace(v reflect.Value) (reflect.Value, bool) {
+	if e.err != nil || !v.IsValid() || !planBuffer(v.Type()).buffer {
+		return v, false
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v, false
+		}
+
+		// concrete value is assignable to the interface holding it.
+		return e.replace(v.Elem())
+	case reflect.Ptr:
+		if v.IsNil() || !e.enter(v) {
+			return v, false
+		}
+
+		elem, ok := e.replace(v.Elem())
+		e.leave(v)
+		if !ok {
+			return v, false
+		}
+
+		// replaced values are new, so they are referenced without a copy.
+		if elem.CanAddr() {
+			return elem.Addr(), true
+		}
+
+		ret := reflect.New(elem.Type())
+		ret.Elem().Set(elem)
+
+		return ret, true
+	case reflect.Struct:
+		if v.Type() == bufferType {
+			return e.replaceBuffer(v)
+		}
+
+		var ret reflect.Value
+		for _, i := range planBuffer(v.Type()).fields {
+			field, ok := e.replace(structField(v, i))
+			if !ok {
+				continue
+			}
+
+			if !ret.IsValid() {
+				ret = reflect.New(v.Type()).Elem()
+				ret.Set(v)
+			}
+			structField(ret, i).Set(field)
+		}
+
+		return ret, ret.IsValid()
 	case reflect.Array, reflect.Slice:
+		if v.Kind() == reflect.Slice {
+			if v.IsNil() || !e.enter(v) {
+				return v, false
+			}
+			defer e.leave(v)
+		}
+
+		var ret reflect.Value
 		for i := 0; i < v.Len(); i++ {
-			b, err := attachBuffer(v.Index(i), index)
-			if err != nil {
-				return nil, err
+			elem, ok := e.replace(v.Index(i))
+			if !ok {
+				continue
 			}
 
-			ret = append(ret, b...)
+			if !ret.IsValid() {
+				ret = copyValue(v)
+			}
+			ret.Index(i).Set(elem)
 		}
+
+		return ret, ret.IsValid()
 	case reflect.Map:
-		for _, key := range v.MapKeys() {
-			b, err := attachBuffer(v.MapIndex(key), index)
-			if err != nil {
-				return nil, err
+		if v.IsNil() || !e.enter(v) {
+			return v, false
+		}
+		defer e.leave(v)
+
+		var ret reflect.Value
+		for _, key := range sortedMapKeys(v) {
+			elem, ok := e.replace(v.MapIndex(key))
+			if !ok {
+				continue
 			}
 
-			ret = append(ret, b...)
+			if !ret.IsValid() {
+				ret = copyValue(v)
+			}
+			ret.SetMapIndex(key, elem)
+		}
+
+		return ret, ret.IsValid()
+	}
+
+	return v, false
+}
+
+func (e *bufferEncoder) replaceBuffer(v reflect.Value) (reflect.Value, bool) {
+	data := v.Field(2).Bytes()
+
+	if e.base64 {
+		// not binary buffer is already encoded as base64.
+		if !v.Field(0).Bool() {
+			return v, false
+		}
+
+		return reflect.ValueOf(&Buffer{Data: data}).Elem(), true
+	}
+
+	buffer := &Buffer{IsBinary: true, Num: uint64(len(e.buffers)), Data: data}
+	e.buffers = append(e.buffers, data)
+
+	return reflect.ValueOf(buffer).Elem(), true
+}
+
+// sortedMapKeys returns keys of map v in order of encoding/json output.
+func sortedMapKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = mapKeyName(key)
+	}
+
+	sort.Sort(mapKeys{keys: keys, names: names})
+
+	return keys
+}
+
+// mapKeyName returns JSON object key of map key v.
+func mapKeyName(v reflect.Value) string {
+	if v.Kind() == reflect.String {
+		return v.String()
+	}
+
+	if tm, ok := v.Interface().(encoding.TextMarshaler); ok {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return ""
+		}
+
+		text, _ := tm.MarshalText()
+
+		return string(text)
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10)
+	}
+
+	return ""
+}
+
+type mapKeys struct {
+	keys  []reflect.Value
+	names []string
+}
+
+func (m mapKeys) Len() int           { return len(m.keys) }
+func (m mapKeys) Less(i, j int) bool { return m.names[i] < m.names[j] }
+func (m mapKeys) Swap(i, j int) {
+	m.keys[i], m.keys[j] = m.keys[j], m.keys[i]
+	m.names[i], m.names[j] = m.names[j], m.names[i]
+}
+
+// copyValue returns shallow copy of array, slice or map v.
+func copyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Slice:
+		ret := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(ret, v)
+
+		return ret
+	case reflect.Map:
+		ret := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for it := v.MapRange(); it.Next(); {
+			ret.SetMapIndex(it.Key(), it.Value())
+		}
+
+		return ret
+	}
+
+	ret := reflect.New(v.Type()).Elem()
+	ret.Set(v)
+
+	return ret
+}
+
+// bindBuffer fills binary placeholders found at any depth of decoded data by
+// the attachments with the same number.
+func bindBuffer(data interface{}, attachments [][]byte) error {
+	switch val := data.(type) {
+	case *Buffer:
+		if !val.IsBinary {
+			return nil
+		}
+
+		if val.Num >= uint64(len(attachments)) {
+			return ErrBufferNum
+		}
+
+		val.Data = attachments[val.Num]
+	case []interface{}:
+		for _, v := range val {
+			if err := bindBuffer(v, attachments); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		for _, v := range val {
+			if err := bindBuffer(v, attachments); err != nil {
+				return err
+			}
 		}
 	}
 
-	return ret, nil
+	return nil
 }
@@ -1,6 +1,8 @@
 package go_socketio_parser
 
 import (
+	"encoding/json"
+	"errors"
 	"reflect"
 	"testing"
 
@@ -111,31 +113,233 @@ var attachDataTests = []attachDataTestCase{
 	},
 }
 
-func TestAttachBuffer(t *testing.T) {
+func TestBufferEncoder(t *testing.T) {
 	for _, test := range attachDataTests {
 		t.Run(test.name, func(t *testing.T) {
-			index := uint64(0)
+			before, err := json.Marshal(test.data)
+			require.NoError(t, err)
+
+			var enc bufferEncoder
+			v, ok := enc.replace(reflect.ValueOf(test.data))
+			require.True(t, ok)
+
+			assert.Equal(t, test.max, uint64(len(enc.buffers)))
+			assert.Equal(t, test.binaries, enc.buffers)
+
+			data, err := json.Marshal(v.Interface())
+			require.NoError(t, err)
+			assert.Contains(t, string(data), `{"_placeholder":true,"num":0}`)
+
+			after, err := json.Marshal(test.data)
+			require.NoError(t, err)
+			assert.Equal(t, string(before), string(after))
+		})
+	}
+}
+
+type bufferNode struct {
+	Children []*bufferNode `json:"children"`
+	Value    int           `json:"value"`
+}
+
+type bufferFields struct {
+	Name   string            `json:"name"`
+	Attrs  map[string]string `json:"attrs"`
+	Files  []Buffer          `json:"files"`
+	Extra  interface{}       `json:"extra"`
+	hidden Buffer
+}
+
+// bufferLoop holds Buffer and refers to itself through bufferLink.
+type bufferLoop struct {
+	Link *bufferLink `json:"link"`
+	Buf  Buffer      `json:"buf"`
+}
+
+type bufferLink struct {
+	Loop *bufferLoop `json:"loop"`
+}
+
+type bufferEmbedded struct {
+	Buf Buffer `json:"buf"`
+}
+
+type bufferPromoted struct {
+	bufferEmbedded
+	Name    string `json:"name"`
+	Skipped Buffer `json:"-"`
+}
+
+type bufferPromotedPtr struct {
+	*bufferEmbedded
+	Name string `json:"name"`
+}
+
+func TestPlanBuffer(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   interface{}
+		buffer bool
+		fields []int
+	}{
+		{"int", 0, false, nil},
+		{"[]int", []int{}, false, nil},
+		{"map[string]string", map[string]string{}, false, nil},
+		{"Buffer", Buffer{}, true, nil},
+		{"*Buffer", &Buffer{}, true, nil},
+		{"[]interface{}", []interface{}{}, true, nil},
+		{"struct", bufferStruct{}, true, []int{1}},
+		{"fields", bufferFields{}, true, []int{2, 3}},
+		{"recursive", bufferNode{}, false, nil},
+		{"recursive buffer", bufferLoop{}, true, []int{0, 1}},
+		{"recursive link", bufferLink{}, true, []int{0}},
+		{"embedded", bufferPromoted{}, true, []int{0}},
+		{"embedded pointer", bufferPromotedPtr{}, true, []int{0}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plan := planBuffer(reflect.TypeOf(test.data))
+			assert.Equal(t, test.buffer, plan.buffer)
+			assert.Equal(t, test.fields, plan.fields)
+		})
+	}
+}
 
-			buf, err := attachBuffer(reflect.ValueOf(test.data), &index)
+func TestBufferEncoder_copiesOnlyPath(t *testing.T) {
+	buffer := Buffer{Data: []byte{1}}
+	attrs := map[string]string{"k": "v"}
+	node := bufferNode{Children: []*bufferNode{{Value: 1}, nil}}
+	data := []interface{}{
+		bufferFields{
+			Attrs: attrs,
+			Files: []Buffer{{Data: []byte{2}}},
+			Extra: &buffer,
+		},
+		node,
+	}
+
+	var enc bufferEncoder
+	v, ok := enc.replace(reflect.ValueOf(data))
+	require.True(t, ok)
+	assert.Equal(t, [][]byte{{2}, {1}}, enc.buffers)
+	assert.Equal(t, Buffer{Data: []byte{1}}, buffer)
+
+	replaced := v.Interface().([]interface{})
+	fields := replaced[0].(bufferFields)
+	assert.Equal(t, []Buffer{{IsBinary: true, Num: 0, Data: []byte{2}}}, fields.Files)
+	assert.Equal(t, &Buffer{IsBinary: true, Num: 1, Data: []byte{1}}, fields.Extra)
+	assert.Equal(t, reflect.ValueOf(attrs).Pointer(), reflect.ValueOf(fields.Attrs).Pointer())
+	assert.Equal(t, node, replaced[1])
+
+	enc = bufferEncoder{}
+	_, ok = enc.replace(reflect.ValueOf([]interface{}{bufferFields{hidden: buffer}, node}))
+	assert.False(t, ok)
+	assert.Empty(t, enc.buffers)
+}
+
+func TestBufferEncoder_embedded(t *testing.T) {
+	for _, data := range []interface{}{
+		bufferPromoted{bufferEmbedded: bufferEmbedded{Buf: Buffer{Data: []byte{1}}}, Name: "x", Skipped: Buffer{Data: []byte{2}}},
+		&bufferPromoted{bufferEmbedded: bufferEmbedded{Buf: Buffer{Data: []byte{1}}}, Name: "x"},
+		bufferPromotedPtr{bufferEmbedded: &bufferEmbedded{Buf: Buffer{Data: []byte{1}}}, Name: "x"},
+	} {
+		before, err := json.Marshal(data)
+		require.NoError(t, err)
+
+		var enc bufferEncoder
+		v, ok := enc.replace(reflect.ValueOf(data))
+		require.True(t, ok)
+		assert.Equal(t, [][]byte{{1}}, enc.buffers)
+
+		encoded, err := json.Marshal(v.Interface())
+		require.NoError(t, err)
+		assert.Equal(t, `{"buf":{"_placeholder":true,"num":0},"name":"x"}`, string(encoded))
+
+		after, err := json.Marshal(data)
+		require.NoError(t, err)
+		assert.Equal(t, string(before), string(after))
+	}
+}
+
+func TestBufferEncoder_mapOrder(t *testing.T) {
+	data := map[string]interface{}{
+		"c": &Buffer{Data: []byte{3}},
+		"a": &Buffer{Data: []byte{1}},
+		"b": map[int]interface{}{
+			10: &Buffer{Data: []byte{22}},
+			2:  &Buffer{Data: []byte{21}},
+		},
+	}
+
+	for i := 0; i < 10; i++ {
+		var enc bufferEncoder
+		_, ok := enc.replace(reflect.ValueOf(data))
+		require.True(t, ok)
+
+		// json sorts "10" before "2".
+		assert.Equal(t, [][]byte{{1}, {22}, {21}, {3}}, enc.buffers)
+	}
+}
+
+func TestBufferEncoder_cycle(t *testing.T) {
+	data := map[string]interface{}{"buf": &Buffer{Data: []byte{1}}}
+	data["self"] = data
+
+	var enc bufferEncoder
+	_, _ = enc.replace(reflect.ValueOf(data))
+
+	var cycleErr *json.UnsupportedValueError
+	assert.True(t, errors.As(enc.err, &cycleErr))
+}
+
+func TestBuffer_JSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		buffer Buffer
+		json   string
+	}{
+		{"placeholder", Buffer{IsBinary: true, Num: 2}, `{"_placeholder":true,"num":2}`},
+		{"base64", Buffer{Data: []byte{1, 2, 255}}, `{"base64":true,"data":"AQL/"}`},
+		{"empty", Buffer{Data: []byte{}}, `{"base64":true,"data":""}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := json.Marshal(test.buffer)
 			require.NoError(t, err)
+			assert.Equal(t, test.json, string(data))
 
-			assert.Equal(t, test.max, index)
-			assert.Equal(t, test.binaries, buf)
+			var buffer Buffer
+			require.NoError(t, json.Unmarshal(data, &buffer))
+			assert.Equal(t, test.buffer, buffer)
 		})
 	}
 }
 
+func TestBufferEncoder_base64(t *testing.T) {
+	buffer := &Buffer{IsBinary: true, Num: 1, Data: []byte{1}}
+	enc := bufferEncoder{base64: true}
+	v, ok := enc.replace(reflect.ValueOf([]interface{}{bufferStruct{Buffer: buffer}, Buffer{Data: []byte{2}}}))
+	require.True(t, ok)
+	assert.Empty(t, enc.buffers)
+	assert.Equal(t, &Buffer{IsBinary: true, Num: 1, Data: []byte{1}}, buffer)
+
+	data, err := json.Marshal(v.Interface())
+	require.NoError(t, err)
+	assert.Equal(t, `[{"i":0,"buf":{"base64":true,"data":"AQ=="}},{"base64":true,"data":"Ag=="}]`, string(data))
+}
+
 func BenchmarkAttachBuffer(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		index := uint64(0)
-
-		_, _ = attachBuffer(reflect.ValueOf(
+		var enc bufferEncoder
+		_, _ = enc.replace(reflect.ValueOf(
 			map[string]interface{}{
 				"data": &Buffer{
 					Data: []byte{1, 2},
 				},
 				"i": 3,
 			},
-		), &index)
+		))
 	}
 }
@@ -0,0 +1,237 @@
+package go_socketio_parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// conformanceCorpusVersion is version of testdata/conformance.json format.
+const conformanceCorpusVersion = 1
+
+// conformanceCase is a case of the reference JavaScript parser test suite.
+// Valid case has packet description in the form of JavaScript packet object,
+// invalid one has the error message of the reference parser and the name of
+// the matching Go error. Differences from the reference case are explained by
+// its note.
+type conformanceCase struct {
+	Name        string             `json:"name"`
+	Source      string             `json:"source"`
+	Note        string             `json:"note"`
+	Packet      *conformancePacket `json:"packet"`
+	Encoded     string             `json:"encoded"`
+	Attachments [][]byte           `json:"attachments"`
+	Error       string             `json:"error"`
+	EncodeError string             `json:"encodeError"`
+	Sentinel    string             `json:"sentinel"`
+}
+
+type conformancePacket struct {
+	Type Type            `json:"type"`
+	Nsp  string          `json:"nsp"`
+	ID   *uint64         `json:"id"`
+	Data json.RawMessage `json:"data"`
+}
+
+func loadConformance(t *testing.T) []conformanceCase {
+	data, err := ioutil.ReadFile("testdata/conformance.json")
+	require.NoError(t, err)
+
+	var corpus struct {
+		Version int               `json:"version"`
+		Cases   []conformanceCase `json:"cases"`
+	}
+	require.NoError(t, json.Unmarshal(data, &corpus))
+	require.Equal(t, conformanceCorpusVersion, corpus.Version)
+
+	return corpus.Cases
+}
+
+// conformanceErrors are Go errors of the corpus sentinel names.
+var conformanceErrors = map[string]error{
+	"ErrUnexpectedEOF":      io.ErrUnexpectedEOF,
+	"ErrInvalidPacket":      ErrInvalidPacket,
+	"ErrInvalidPayload":     ErrInvalidPayload,
+	"ErrInvalidPackageType": ErrInvalidPackageType,
+	"ErrIllegalAttachments": ErrIllegalAttachments,
+	"ErrInvalidPlaceholder": ErrInvalidPlaceholder,
+	"ErrMissingAttachments": ErrMissingAttachments,
+}
+
+func assertConformanceError(t *testing.T, test conformanceCase, err error) {
+	t.Helper()
+
+	if test.Sentinel == "UnsupportedValueError" {
+		var valueErr *json.UnsupportedValueError
+		assert.True(t, errors.As(err, &valueErr), "expected %s, got %v", test.Sentinel, err)
+
+		return
+	}
+
+	sentinel, ok := conformanceErrors[test.Sentinel]
+	require.True(t, ok, "unknown sentinel %q", test.Sentinel)
+	assert.True(t, errors.Is(err, sentinel), "expected %s, got %v", test.Sentinel, err)
+}
+
+func conformanceCycle() map[string]interface{} {
+	a := map[string]interface{}{}
+	a["b"] = a
+
+	return a
+}
+
+// conformanceOverrides are Go packets of the cases which can't be described by
+// the corpus packet, every one is explained by the case note.
+var conformanceOverrides = map[string]*Packet{
+	"throws an error when encoding circular objects": {
+		Header: Header{Type: Event, ID: 1, HasID: true},
+		Data:   []interface{}{conformanceCycle()},
+	},
+}
+
+// goPacket returns Go packet of the case: it is built from the corpus packet
+// description, placeholders are replaced by buffers of the case attachments
+// and namespace "/" is the default empty one.
+func (c conformanceCase) goPacket(t *testing.T) *Packet {
+	t.Helper()
+
+	if packet, ok := conformanceOverrides[c.Name]; ok {
+		require.NotEmpty(t, c.Note, "override of case %q should be explained by note", c.Name)
+		return packet
+	}
+	require.NotNil(t, c.Packet, "no packet of case %q", c.Name)
+
+	p := c.Packet
+	packet := &Packet{Header: Header{Type: p.Type}}
+	if p.Nsp != "/" {
+		packet.Header.Namespace = p.Nsp
+	}
+	if p.ID != nil {
+		packet.Header.ID = *p.ID
+		packet.Header.HasID = true
+	}
+
+	if p.Data == nil {
+		return packet
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(p.Data))
+	dec.UseNumber()
+
+	var data interface{}
+	require.NoError(t, dec.Decode(&data))
+	data = conformanceValue(t, data, c.Attachments)
+
+	switch p.Type {
+	case Connect:
+		packet.Payload = data
+	case Error:
+		// CONNECT_ERROR object is decoded to ConnectErrorPayload.
+		if m, ok := data.(map[string]interface{}); ok {
+			packet.Payload = &ConnectErrorPayload{Message: m["message"].(string), Data: m["data"]}
+		} else {
+			packet.Payload = data
+		}
+	default:
+		packet.Data = data.([]interface{})
+	}
+
+	return packet
+}
+
+// conformanceValue converts JavaScript value of the corpus to Go one: numbers
+// to int or float64 and placeholders to buffers of the attachments.
+func conformanceValue(t *testing.T, v interface{}, attachments [][]byte) interface{} {
+	switch val := v.(type) {
+	case json.Number:
+		if i, err := strconv.Atoi(val.String()); err == nil {
+			return i
+		}
+
+		f, err := strconv.ParseFloat(val.String(), 64)
+		require.NoError(t, err)
+
+		return f
+	case []interface{}:
+		for i := range val {
+			val[i] = conformanceValue(t, val[i], attachments)
+		}
+	case map[string]interface{}:
+		if val["_placeholder"] == true && len(val) == 2 {
+			num, err := strconv.ParseUint(val["num"].(json.Number).String(), 10, 64)
+			require.NoError(t, err)
+			require.Less(t, num, uint64(len(attachments)))
+
+			return &Buffer{IsBinary: true, Num: num, Data: attachments[num]}
+		}
+
+		for key := range val {
+			val[key] = conformanceValue(t, val[key], attachments)
+		}
+	}
+
+	return v
+}
+
+func TestConformance_overrides(t *testing.T) {
+	names := map[string]bool{}
+	for _, test := range loadConformance(t) {
+		names[test.Name] = true
+	}
+
+	for name := range conformanceOverrides {
+		assert.True(t, names[name], "override of unknown case %q", name)
+	}
+}
+
+func TestConformance_encode(t *testing.T) {
+	for _, test := range loadConformance(t) {
+		if test.Packet == nil && test.EncodeError == "" {
+			continue
+		}
+
+		t.Run(test.Name, func(t *testing.T) {
+			packet := test.goPacket(t)
+
+			if test.EncodeError != "" {
+				_, _, err := MarshalFrames(packet)
+				assertConformanceError(t, test, err)
+
+				return
+			}
+
+			text, attachments, err := MarshalFrames(packet, WithValidation())
+			require.NoError(t, err)
+
+			assert.Equal(t, test.Encoded, text)
+			assert.Equal(t, test.Attachments, attachments)
+		})
+	}
+}
+
+func TestConformance_decode(t *testing.T) {
+	for _, test := range loadConformance(t) {
+		if test.EncodeError != "" {
+			continue
+		}
+
+		t.Run(test.Name, func(t *testing.T) {
+			var message Packet
+			err := UnmarshalFrames(test.Encoded, test.Attachments, &message, WithValidation())
+			if test.Packet == nil {
+				assertConformanceError(t, test, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, test.goPacket(t), &message)
+		})
+	}
+}
@@ -2,126 +2,259 @@ package go_socketio_parser
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"math"
 	"strconv"
+	"strings"
+	"unsafe"
 )
 
 const binarySep = byte('-')
 const nsSep = byte('/')
 const nsEndSep = byte(',')
 const dataOpenSep = byte('[')
-const dataCloseSep = byte(']')
-const payloadSep = byte(',')
 const attachBinarySep = byte('\n')
-const bufferOpenDataSep = byte('{')
-const bufferCloseDataSep = byte('}')
-
-func Unmarshal(data []byte, message *Packet) error {
+const querySep = byte('?')
+
+// defaultNamespace is connected implicitly in protocol v4, it is kept as empty
+// Header.Namespace.
+const defaultNamespace = "/"
+
+// Unmarshal packet header with request payload. Binary attachments are expected
+// inline, each one prefixed by '\n' as written by Marshal.
+// Payload is decoded as by json.Unmarshal into interface{}, except numbers:
+// integral values fitting in int are decoded as int, others as float64.
+// Malformed input is reported by *DecodeError.
+func Unmarshal(data []byte, message *Packet, opts ...Option) error {
+	if message == nil {
+		return errors.New("empty output header destination")
+	}
 	if len(data) == 0 {
-		return errors.New("empty input data")
+		return &DecodeError{Section: SectionType, Err: io.ErrUnexpectedEOF}
 	}
+
+	o := newOptions(opts)
+	if err := o.limits.checkPacketSize(len(data)); err != nil {
+		return err
+	}
+
+	if _, err := decodePacket(data, message, true, o); err != nil {
+		return err
+	}
+
+	return o.check(message)
+}
+
+// UnmarshalFrames packet from the text frame and the binary attachments frames
+// as they are received from transport. Attachments are copied, unless
+// WithZeroCopy is set, so transport may reuse them.
+func UnmarshalFrames(text string, attachments [][]byte, message *Packet, opts ...Option) error {
 	if message == nil {
 		return errors.New("empty output header destination")
 	}
+	if len(text) == 0 {
+		return &DecodeError{Section: SectionType, Err: io.ErrUnexpectedEOF}
+	}
+
+	o := newOptions(opts)
 
-	r := bytes.NewReader(data)
+	size := len(text)
+	for _, attachment := range attachments {
+		size += len(attachment)
+	}
+	if err := o.limits.checkPacketSize(size); err != nil {
+		return err
+	}
+
+	count, err := decodePacket([]byte(text), message, false, o)
+	if err != nil {
+		return err
+	}
+
+	for _, attachment := range attachments {
+		if err = o.limits.checkAttachmentSize(len(attachment)); err != nil {
+			return &DecodeError{Offset: int64(len(text)), Section: SectionAttachment, Err: err}
+		}
+	}
+
+	if count != uint64(len(attachments)) {
+		return &DecodeError{Offset: int64(len(text)), Section: SectionAttachment, Err: ErrMissingAttachments}
+	}
+
+	// with zeroCopy attachments are kept as is, transports may reuse them otherwise.
+	if !o.zeroCopy {
+		attachments = copyAttachments(attachments)
+	}
+
+	if err = bindBuffer(message.Data, attachments); err != nil {
+		return &DecodeError{Offset: int64(len(text)), Section: SectionAttachment, Err: err}
+	}
+
+	return o.check(message)
+}
+
+// copyAttachments copies attachments into a single allocation.
+func copyAttachments(attachments [][]byte) [][]byte {
+	size := 0
+	for _, attachment := range attachments {
+		size += len(attachment)
+	}
+
+	buf := make([]byte, 0, size)
+	ret := make([][]byte, len(attachments))
+	for i, attachment := range attachments {
+		start := len(buf)
+		buf = append(buf, attachment...)
+		ret[i] = buf[start:len(buf):len(buf)]
+	}
+
+	return ret
+}
+
+// decodePacket reads packet header and payload. When inline is false the binary
+// attachments are not read and their declared count is returned instead.
+func decodePacket(src []byte, message *Packet, inline bool, o options) (uint64, error) {
+	r := bytes.NewReader(src)
 
 	// read <packet type>
 	nextByte, err := r.ReadByte()
 	if err != nil {
-		return err
+		return 0, &DecodeError{Section: SectionType, Err: io.ErrUnexpectedEOF}
 	}
 
 	ht := Type(nextByte - zeroNumberByte)
 	if !ht.IsValid() {
-		return ErrInvalidPackageType
+		return 0, &DecodeError{Section: SectionType, Err: ErrInvalidPackageType}
 	}
 	message.Header.Type = ht
 
-	num, err := readUint64(r)
-	if err != nil && err != io.EOF {
-		return err
-	}
-
+	var attachments uint64
 	if ht.IsBinary() {
-		// count binary attachments: <count of binary attachments>
-		if num != 0 {
-			nextByte, err = r.ReadByte()
-			if err != nil && err != io.EOF {
-				return err
-			}
+		// count binary attachments: <count of binary attachments>-
+		start := offset(r)
 
-			// "-"
-			if nextByte == binarySep {
-				message.Header.Type -= binaryTypeShift
-			}
+		num, ok, err := readUint64(r)
+		if err != nil {
+			return 0, &DecodeError{Offset: start, Section: SectionAttachments, Err: err}
 		}
-	} else if num != 0 {
-		message.Header.ID = num
-	}
 
-	nextByte, err = r.ReadByte()
-	if err != nil && err != io.EOF {
-		return err
+		nextByte, err = r.ReadByte()
+		if !ok || err != nil || nextByte != binarySep {
+			return 0, &DecodeError{Offset: start, Section: SectionAttachments, Err: ErrIllegalAttachments}
+		}
+
+		if err = o.limits.checkAttachments(num); err != nil {
+			return 0, &DecodeError{Offset: start, Section: SectionAttachments, Err: err}
+		}
+
+		if num != 0 {
+			message.Header.Type -= binaryTypeShift
+			attachments = num
+		}
 	}
 
+	// read namespace
+	nextByte, err = r.ReadByte()
 	if err == io.EOF {
-		return nil
+		return attachments, verifyPlaceholders(r, nil, attachments)
 	}
+	_ = r.UnreadByte()
 
-	// read namespace
 	if nextByte == nsSep {
-		_ = r.UnreadByte()
+		start := offset(r)
 
-		ns, err := readString(r)
-		if err != nil && err != io.EOF {
-			return err
+		ns := readString(r, src, o.zeroCopy)
+		if err = o.limits.checkNamespace(len(ns)); err != nil {
+			return 0, &DecodeError{Offset: start, Section: SectionNamespace, Err: err}
 		}
 
-		message.Header.Namespace = ns
+		// protocol v4 sends query parameters of CONNECT inside namespace.
+		if o.protocol == ProtocolV4 && ht == Connect {
+			if idx := strings.IndexByte(ns, querySep); idx >= 0 {
+				ns, message.Header.Query = ns[:idx], ns[idx+1:]
+			}
+		}
 
-		if err == io.EOF {
-			return nil
+		if o.protocol == ProtocolV4 && ns == defaultNamespace {
+			ns = ""
 		}
+
+		message.Header.Namespace = ns
 	}
 
-	if message.Header.ID == 0 {
-		//notice: this case handle example: binary packet type, buffer and without nsp.
-		if isNumberByte(nextByte) {
-			_ = r.UnreadByte()
-		}
+	// read acknowledgment id
+	start := offset(r)
 
-		// read acknowledgment id
-		id, err := readUint64(r)
-		if err != nil && err != io.EOF {
-			return err
-		}
+	id, ok, err := readUint64(r)
+	if err != nil {
+		return 0, &DecodeError{Offset: start, Section: SectionID, Err: err}
+	}
 
-		if id != 0 {
-			message.Header.ID = id
-		}
+	if ok {
+		message.Header.ID = id
+		message.Header.HasID = true
 	}
 
-	if err == io.EOF {
-		return nil
+	if r.Len() == 0 {
+		return attachments, verifyPlaceholders(r, nil, attachments)
 	}
 
-	// hotfix
-	if nextByte == '[' {
+	// protocol v4 CONNECT packet has no payload.
+	if o.protocol == ProtocolV4 && ht == Connect {
+		return 0, &DecodeError{Offset: offset(r), Section: SectionPayload, Err: ErrInvalidPayload}
+	}
+
+	// CONNECT and CONNECT_ERROR packets carry single value instead of array.
+	if ht == Connect || ht == Error {
+		nextByte, _ = r.ReadByte()
 		_ = r.UnreadByte()
+
+		if nextByte != dataOpenSep {
+			payload, err := decodeObject(r, ht, o)
+			if err != nil {
+				return 0, err
+			}
+
+			message.Payload = payload
+
+			if r.Len() > 0 {
+				return 0, &DecodeError{Offset: offset(r), Section: SectionPayload, Err: ErrInvalidPayload}
+			}
+
+			return attachments, nil
+		}
 	}
 
 	// notice: if packet type == event or binaryEvent usual exists by zero index event message.
-	decodeData, err := decodeData(r)
-	if err != nil && err != io.EOF {
-		return err
+	var data []interface{}
+	if inline {
+		data, err = decodeData(r, src, ht.IsBinary(), attachments, o)
+	} else {
+		var buffers []*Buffer
+		data, buffers, err = decodePayload(r, src, ht.IsBinary(), o)
+		if err == nil {
+			err = verifyPlaceholders(r, buffers, attachments)
+		}
+		if err == nil && r.Len() > 0 {
+			err = &DecodeError{Offset: offset(r), Section: SectionPayload, Err: ErrInvalidPayload}
+		}
+	}
+	if err != nil {
+		return 0, err
 	}
 
-	message.Data = decodeData
+	message.Data = data
 
-	return nil
+	return attachments, nil
+}
+
+// offset returns position of the next byte to read.
+func offset(r *bytes.Reader) int64 {
+	return r.Size() - int64(r.Len())
 }
 
 const zeroNumberByte = byte('0')
@@ -131,180 +264,499 @@ func isNumberByte(b byte) bool {
 	return zeroNumberByte <= b && b <= nineNumberByte
 }
 
-func readUint64(r *bytes.Reader) (uint64, error) {
+// readUint64 reads decimal number and reports whether any digit was read.
+func readUint64(r *bytes.Reader) (uint64, bool, error) {
 	var res uint64
+	var ok bool
 
 	for {
 		b, err := r.ReadByte()
-		if err != nil && err != io.EOF {
-			return 0, err
-		}
 		if err == io.EOF {
-			return res, nil
+			return res, ok, nil
 		}
 
-		if !(zeroNumberByte <= b && b <= nineNumberByte) {
+		if !isNumberByte(b) {
 			_ = r.UnreadByte()
-			return res, nil
+			return res, ok, nil
 		}
 
-		res = res*10 + uint64(b-zeroNumberByte)
+		digit := uint64(b - zeroNumberByte)
+		if res > (math.MaxUint64-digit)/10 {
+			return 0, false, ErrNumberOverflow
+		}
+
+		res = res*10 + digit
+		ok = true
 	}
 }
 
-func readString(r *bytes.Reader) (string, error) {
-	var ret bytes.Buffer
+// readString reads namespace up to ',' separator or the end of input. With
+// zeroCopy the string shares memory with src.
+func readString(r *bytes.Reader, src []byte, zeroCopy bool) string {
+	start := offset(r)
 
-	for {
-		b, err := r.ReadByte()
-		if err != nil && err != io.EOF {
-			return "", err
-		}
-		if err == io.EOF || b == nsEndSep {
-			return ret.String(), nil
-		}
+	ns := src[start:]
+	next := len(ns)
+	if idx := bytes.IndexByte(ns, nsEndSep); idx >= 0 {
+		ns, next = ns[:idx], idx+1
+	}
+	_, _ = r.Seek(start+int64(next), io.SeekStart)
 
-		if err = ret.WriteByte(b); err != nil {
-			return "", err
-		}
+	if zeroCopy {
+		return unsafeString(ns)
 	}
+
+	return string(ns)
 }
 
-func readJSONPayload(r *bytes.Reader) (interface{}, error) {
-	var buf bytes.Buffer
-	var attachJSON Buffer
+// unsafeString returns string sharing memory with b, b must not be changed.
+func unsafeString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
 
-	for {
-		b, err := r.ReadByte()
-		if err != nil {
-			return nil, err
+	return *(*string)(unsafe.Pointer(&b))
+}
+
+// decodeData reads payload followed by count of inline binary attachments.
+func decodeData(r *bytes.Reader, src []byte, binary bool, count uint64, o options) ([]interface{}, error) {
+	data, buffers, err := decodePayload(r, src, binary, o)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = verifyPlaceholders(r, buffers, count); err != nil {
+		return nil, err
+	}
+
+	start := offset(r)
+	if count == 0 {
+		if r.Len() > 0 {
+			return nil, &DecodeError{Offset: start, Section: SectionPayload, Err: ErrInvalidPayload}
 		}
 
-		buf.WriteByte(b)
+		return data, nil
+	}
+
+	nextByte, err := r.ReadByte()
+	if err == io.EOF || nextByte != attachBinarySep {
+		return nil, &DecodeError{Offset: start, Section: SectionAttachment, Err: ErrMissingAttachments}
+	}
+
+	// with zeroCopy attachments share memory with src.
+	rest := src[offset(r):]
+	if !o.zeroCopy {
+		rest = append(make([]byte, 0, len(rest)), rest...)
+	}
+	_, _ = r.Seek(0, io.SeekEnd)
+
+	// the last attachment takes the rest of input, so it may contain separator.
+	attachments := bytes.SplitN(rest, []byte{attachBinarySep}, int(count))
+	if len(attachments) < int(count) {
+		return nil, &DecodeError{Offset: start, Section: SectionAttachment, Err: ErrMissingAttachments}
+	}
 
-		if b == bufferCloseDataSep {
-			break
+	for _, attachment := range attachments {
+		if err = o.limits.checkAttachmentSize(len(attachment)); err != nil {
+			return nil, &DecodeError{Offset: start, Section: SectionAttachment, Err: err}
 		}
 	}
 
-	if err := json.Unmarshal(buf.Bytes(), &attachJSON); err != nil {
-		return nil, err
+	if err = bindBuffer(data, attachments); err != nil {
+		return nil, &DecodeError{Offset: start, Section: SectionAttachment, Err: err}
 	}
 
-	return &attachJSON, nil
+	return data, nil
 }
 
-func decodeData(r *bytes.Reader) ([]interface{}, error) {
+// decodePayload reads JSON-stringified payload array and returns binary
+// placeholders found in it, they are resolved for binary packets only.
+// JSON numbers are decoded as int when they are integral and fit in int,
+// otherwise as float64.
+func decodePayload(r *bytes.Reader, src []byte, binary bool, o options) ([]interface{}, []*Buffer, error) {
+	start := offset(r)
+
 	b, err := r.ReadByte()
-	if err != nil && err != io.EOF {
-		return nil, err
+	if err != nil {
+		return nil, nil, &DecodeError{Offset: start, Section: SectionPayload, Err: io.ErrUnexpectedEOF}
 	}
 
-	if err == io.EOF {
-		return nil, err
+	if b != dataOpenSep {
+		return nil, nil, &DecodeError{Offset: start, Section: SectionPayload, Err: ErrInvalidPayload}
 	}
+	_ = r.UnreadByte()
 
-	if b != dataOpenSep {
-		return nil, errors.New("invalid data segment")
+	if err = checkJSON(r, o.limits); err != nil {
+		return nil, nil, err
 	}
 
-	bufferIdx := map[int]struct{}{}
 	var data []interface{}
+	if o.zeroCopy {
+		data, err = readRawArgs(r, src)
+	} else {
+		err = readJSON(r, &data)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
 
-	//parse JSON-stringified payload.
-	var countData int
-	var buf bytes.Buffer
+	var buffers []*Buffer
+	for idx := range data {
+		if raw, ok := data[idx].(json.RawMessage); ok {
+			// raw argument is decoded only to resolve buffers inside it.
+			if !(binary && bytes.Contains(raw, placeholderKey)) && !(o.base64 && bytes.Contains(raw, base64Key)) {
+				continue
+			}
 
-	for {
-		b, err := r.ReadByte()
-		if err != nil && err != io.EOF {
-			return nil, err
+			if err = readJSON(bytes.NewReader(raw), &data[idx]); err != nil {
+				return nil, nil, &DecodeError{Offset: start, Section: SectionPayload, Err: ErrInvalidPayload}
+			}
 		}
-		if b == '"' {
-			continue
+
+		if data[idx], err = normalizeJSON(data[idx], binary, o.base64, &buffers); err != nil {
+			return nil, nil, &DecodeError{Offset: start, Section: SectionPayload, Err: err}
 		}
-		if b == bufferOpenDataSep {
-			_ = r.UnreadByte()
-			jsonPayload, err := readJSONPayload(r)
-			if err != nil {
-				return nil, err
-			}
-			bufferIdx[countData] = struct{}{}
+	}
 
-			data = append(data, jsonPayload)
-			countData++
+	if err = o.limits.checkAttachments(uint64(len(buffers))); err != nil {
+		return nil, nil, &DecodeError{Offset: start, Section: SectionPayload, Err: err}
+	}
+
+	return data, buffers, nil
+}
+
+var (
+	placeholderKey = []byte(`"_placeholder"`)
+	base64Key      = []byte(`"base64"`)
+)
+
+// readRawArgs reads JSON array at the current position of r as arguments of
+// json.RawMessage sharing memory with src.
+func readRawArgs(r *bytes.Reader, src []byte) ([]interface{}, error) {
+	start := offset(r)
+
+	var (
+		args              []interface{}
+		depth             int
+		inString, escaped bool
+	)
+
+	array := src[start:]
+	next := 1
+	for i, b := range array {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == '"':
+				inString = false
+			}
 
 			continue
 		}
-		if b == dataCloseSep {
-			// todo action with buf
-			break
+
+		switch b {
+		case '"':
+			inString = true
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
 		}
 
-		if b == payloadSep && buf.Len() == 0 {
-			continue
+		// top-level argument ends by ',' or by the end of array.
+		if (depth == 1 && b == ',') || depth == 0 {
+			if arg := bytes.TrimSpace(array[next:i]); len(arg) > 0 {
+				args = append(args, json.RawMessage(arg))
+			}
+			next = i + 1
 		}
 
-		if b == payloadSep {
-			data = append(data, buf.String())
-			countData++
+		if depth == 0 {
+			if !json.Valid(array[:i+1]) || !numbersInRange(array[:i+1]) {
+				return nil, &DecodeError{Offset: start, Section: SectionPayload, Err: ErrInvalidPayload}
+			}
+			_, _ = r.Seek(start+int64(i+1), io.SeekStart)
 
-			buf.Reset()
+			if args == nil {
+				args = []interface{}{}
+			}
 
-			continue
+			return args, nil
 		}
+	}
+
+	return nil, &DecodeError{Offset: r.Size(), Section: SectionPayload, Err: io.ErrUnexpectedEOF}
+}
 
-		buf.WriteByte(b)
+// decodeObject reads payload of CONNECT and CONNECT_ERROR packets.
+func decodeObject(r *bytes.Reader, ht Type, o options) (interface{}, error) {
+	if err := checkJSON(r, Limits{MaxDepth: o.limits.MaxDepth}); err != nil {
+		return nil, err
 	}
 
-	nextByte, err := r.ReadByte()
-	if err != nil && err != io.EOF {
+	start := offset(r)
+
+	var payload interface{}
+	if err := readJSON(r, &payload); err != nil {
 		return nil, err
 	}
-	if len(bufferIdx) > 0 && (err == io.EOF || nextByte != attachBinarySep) {
-		return nil, errors.New("not found binary attachments")
+
+	// number payload can't be told apart from acknowledgment id, neither
+	// array from arguments when JSON is preceded by whitespace.
+	switch payload.(type) {
+	case json.Number, []interface{}:
+		return nil, &DecodeError{Offset: start, Section: SectionPayload, Err: ErrInvalidPayload}
+	}
+
+	payload, err := normalizeJSON(payload, false, o.base64, nil)
+	if err != nil {
+		return nil, &DecodeError{Offset: start, Section: SectionPayload, Err: err}
 	}
 
-	if buf.Len() > 0 {
-		str := buf.String()
-		val, err := strconv.Atoi(str)
-		if err != nil {
-			data = append(data, str)
-		} else {
-			data = append(data, val)
+	if m, ok := payload.(map[string]interface{}); ok && ht == Error && o.protocol != ProtocolV4 {
+		if msg, ok := m["message"].(string); ok {
+			return &ConnectErrorPayload{
+				Message: msg,
+				Data:    m["data"],
+			}, nil
 		}
 	}
 
-	for idx := range bufferIdx {
-		var buf bytes.Buffer
-		for {
-			b, err := r.ReadByte()
-			if err != nil && err != io.EOF {
-				return nil, err
+	return payload, nil
+}
+
+// checkJSON checks JSON value at the current position of r by limits.
+func checkJSON(r *bytes.Reader, limits Limits) error {
+	start := offset(r)
+
+	pos, err := limits.checkJSON(r)
+	_, _ = r.Seek(start, io.SeekStart)
+	if err != nil {
+		return &DecodeError{Offset: start + int64(pos), Section: SectionPayload, Err: err}
+	}
+
+	return nil
+}
+
+// numbersInRange reports whether every number of valid JSON fits in float64,
+// as it is checked by encoding/json.
+func numbersInRange(data []byte) bool {
+	var inString, escaped bool
+
+	start := -1
+	for i := 0; i <= len(data); i++ {
+		var b byte
+		if i < len(data) {
+			b = data[i]
+		}
+
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == '"':
+				inString = false
 			}
 
-			if buf.Len() == 0 && err == io.EOF {
-				break
+			continue
+		}
+
+		if start >= 0 {
+			if i < len(data) && (isNumberByte(b) || bytes.IndexByte(numberChars, b) >= 0) {
+				continue
 			}
 
-			if err == io.EOF || b == attachBinarySep {
-				bufferData, ok := data[idx].(*Buffer)
-				// notice: so strange behaviour
-				if !ok {
-					return nil, errors.New("")
+			// only exponent or hundreds of digits exceed float64 range.
+			number := data[start:i]
+			if len(number) > maxFloatDigits || bytes.IndexAny(number, "eE") >= 0 {
+				if _, err := strconv.ParseFloat(string(number), 64); err != nil {
+					return false
 				}
+			}
+			start = -1
+		}
+
+		switch {
+		case b == '"':
+			inString = true
+		case b == '-' || isNumberByte(b):
+			start = i
+		}
+	}
+
+	return true
+}
+
+// numberChars are non-digit characters of JSON number.
+var numberChars = []byte("+-.eE")
 
-				bufferData.Data = buf.Bytes()
-				data[idx] = bufferData
+// maxFloatDigits is count of digits of numbers which always fit in float64.
+const maxFloatDigits = 308
 
-				buf.Reset()
+// readJSON reads single JSON value and leaves r right after it.
+func readJSON(r *bytes.Reader, v interface{}) error {
+	start := offset(r)
 
-				break
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	if err := dec.Decode(v); err != nil {
+		var syntaxErr *json.SyntaxError
+		switch {
+		case err == io.EOF || err == io.ErrUnexpectedEOF:
+			return &DecodeError{Offset: r.Size(), Section: SectionPayload, Err: io.ErrUnexpectedEOF}
+		case errors.As(err, &syntaxErr):
+			return &DecodeError{Offset: start + syntaxErr.Offset - 1, Section: SectionPayload, Err: err}
+		}
+
+		return &DecodeError{Offset: start, Section: SectionPayload, Err: err}
+	}
+
+	// json decoder reads ahead, so move back to the end of value.
+	_, _ = r.Seek(start+dec.InputOffset(), io.SeekStart)
+
+	return nil
+}
+
+// verifyPlaceholders checks that placeholders are numbered exactly from 0 to
+// the declared count of attachments, without duplicates.
+func verifyPlaceholders(r *bytes.Reader, buffers []*Buffer, count uint64) error {
+	if uint64(len(buffers)) != count {
+		return &DecodeError{Offset: offset(r), Section: SectionPayload, Err: ErrIllegalAttachments}
+	}
+
+	seen := make([]bool, count)
+	for _, buffer := range buffers {
+		if buffer.Num >= count || seen[buffer.Num] {
+			return &DecodeError{Offset: offset(r), Section: SectionPayload, Err: ErrInvalidPlaceholder}
+		}
+
+		seen[buffer.Num] = true
+	}
+
+	return nil
+}
+
+// placeholder returns binary buffer for {"_placeholder":true,"num":N} object.
+// Object with "_placeholder":true and num not a non-negative integer is invalid.
+func placeholder(v map[string]interface{}) (*Buffer, bool, error) {
+	isBinary, ok := v["_placeholder"].(bool)
+	if !ok || !isBinary {
+		return nil, false, nil
+	}
+
+	num, ok := v["num"].(json.Number)
+	if !ok {
+		return nil, true, ErrInvalidPlaceholder
+	}
+
+	n, err := strconv.ParseUint(num.String(), 10, 64)
+	if err != nil {
+		return nil, true, ErrInvalidPlaceholder
+	}
+
+	return &Buffer{
+		IsBinary: true,
+		Num:      n,
+	}, true, nil
+}
+
+// inlinedBuffer returns buffer for {"base64":true,"data":"<base64>"} object.
+// Objects with other keys are user data and are kept as is.
+func inlinedBuffer(v map[string]interface{}) (*Buffer, bool, error) {
+	if len(v) != 2 {
+		return nil, false, nil
+	}
+
+	isBase64, ok := v["base64"].(bool)
+	if !ok || !isBase64 {
+		return nil, false, nil
+	}
+
+	raw, ok := v["data"]
+	if !ok {
+		return nil, false, nil
+	}
+
+	encoded, ok := raw.(string)
+	if !ok {
+		return nil, true, ErrInvalidPayload
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, true, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+
+	return &Buffer{
+		Data: data,
+	}, true, nil
+}
+
+// normalizeJSON replaces json.Number values by int or float64 and, when
+// binary is set, placeholders by *Buffer at any depth collecting them. When
+// inlined is set, base64 buffers are replaced by *Buffer too.
+func normalizeJSON(v interface{}, binary, inlined bool, buffers *[]*Buffer) (interface{}, error) {
+	var err error
+
+	switch val := v.(type) {
+	case json.Number:
+		if i, err := strconv.Atoi(val.String()); err == nil {
+			return i, nil
+		}
+
+		// encoding/json rejects numbers out of float64 range too.
+		f, err := val.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+		}
+
+		// integral values written with fraction or exponent, e.g. 1.0 or 1e2.
+		if f == math.Trunc(f) && f >= math.MinInt && f < -math.MinInt {
+			return int(f), nil
+		}
+
+		return f, nil
+	case []interface{}:
+		for idx := range val {
+			if val[idx], err = normalizeJSON(val[idx], binary, inlined, buffers); err != nil {
+				return nil, err
 			}
+		}
+	case map[string]interface{}:
+		if binary {
+			buffer, ok, err := placeholder(val)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				*buffers = append(*buffers, buffer)
+
+				return buffer, nil
+			}
+		}
+
+		if inlined {
+			buffer, ok, err := inlinedBuffer(val)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				return buffer, nil
+			}
+		}
 
-			buf.WriteByte(b)
+		for key := range val {
+			if val[key], err = normalizeJSON(val[key], binary, inlined, buffers); err != nil {
+				return nil, err
+			}
 		}
 	}
 
-	return data, nil
+	return v, nil
 }
@@ -2,6 +2,11 @@ package go_socketio_parser
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -17,6 +22,7 @@ func TestDecoder(t *testing.T) {
 			require.Equal(t, len(test.Data), len(message.Data))
 
 			assert.Equal(t, test.Header, message.Header)
+			assert.Equal(t, test.Payload, message.Payload)
 			for idx, data := range test.Data {
 				assert.Equal(t, data, message.Data[idx])
 			}
@@ -24,16 +30,422 @@ func TestDecoder(t *testing.T) {
 	}
 }
 
-func Test_readJSONPayload(t *testing.T) {
-	t.Run("empty json", func(t *testing.T) {
-		data := []byte(`{}`)
-		r := bytes.NewReader(data)
+func TestUnmarshal_protocolV4(t *testing.T) {
+	for _, test := range testsV4 {
+		t.Run(test.Name, func(t *testing.T) {
+			var message Packet
+			err := Unmarshal([]byte(test.Tmpl), &message, WithProtocolVersion(ProtocolV4), WithValidation())
+			require.NoError(t, err)
+			require.Equal(t, len(test.Data), len(message.Data))
+
+			assert.Equal(t, test.Header, message.Header)
+			assert.Equal(t, test.Payload, message.Payload)
+			for idx, data := range test.Data {
+				assert.Equal(t, data, message.Data[idx])
+			}
+		})
+	}
+
+	t.Run("connect payload", func(t *testing.T) {
+		var message Packet
+		err := Unmarshal([]byte(`0{"token":"abc"}`), &message, WithProtocolVersion(ProtocolV4))
+		assert.True(t, errors.Is(err, ErrInvalidPayload))
+	})
+
+	t.Run("default nsp", func(t *testing.T) {
+		for tmpl, header := range map[string]Header{
+			"0/":           {Type: Connect},
+			`2/,1["msg"]`:  {Type: Event, ID: 1, HasID: true},
+			"0/?token=abc": {Type: Connect, Query: "token=abc"},
+		} {
+			var message Packet
+			require.NoError(t, Unmarshal([]byte(tmpl), &message, WithProtocolVersion(ProtocolV4)))
+			assert.Equal(t, header, message.Header, tmpl)
+		}
+
+		var message Packet
+		require.NoError(t, Unmarshal([]byte("0/"), &message))
+		assert.Equal(t, "/", message.Header.Namespace)
+	})
+
+	t.Run("query in protocol v5", func(t *testing.T) {
+		var message Packet
+		require.NoError(t, Unmarshal([]byte(`0/admin?token=abc`), &message))
+		assert.Equal(t, "/admin?token=abc", message.Header.Namespace)
+		assert.Empty(t, message.Header.Query)
+	})
+}
+
+func TestUnmarshal_base64Binary(t *testing.T) {
+	data := []byte(`2["upload",{"base64":true,"data":"AQID"},{"file":{"base64":true,"data":"aGk="}}]`)
+
+	var message Packet
+	require.NoError(t, Unmarshal(data, &message, WithBase64Binary()))
+	assert.Equal(t, Event, message.Header.Type)
+	assert.Equal(t, []interface{}{
+		"upload",
+		&Buffer{Data: []byte{1, 2, 3}},
+		map[string]interface{}{"file": &Buffer{Data: []byte("hi")}},
+	}, message.Data)
+
+	var args struct {
+		File Buffer `json:"file"`
+	}
+	var buffer Buffer
+	require.NoError(t, message.DecodeArgs(nil, &buffer, &args))
+	assert.Equal(t, []byte{1, 2, 3}, buffer.Data)
+	assert.Equal(t, []byte("hi"), args.File.Data)
+
+	t.Run("disabled", func(t *testing.T) {
+		var message Packet
+		require.NoError(t, Unmarshal(data, &message))
+		assert.Equal(t, map[string]interface{}{"base64": true, "data": "AQID"}, message.Data[1])
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		var message Packet
+		err := Unmarshal([]byte(`2["upload",{"base64":true,"data":"!"}]`), &message, WithBase64Binary())
+		assert.True(t, errors.Is(err, ErrInvalidPayload))
+
+		err = Unmarshal([]byte(`2["upload",{"base64":true,"data":1}]`), &message, WithBase64Binary())
+		assert.True(t, errors.Is(err, ErrInvalidPayload))
+	})
+
+	t.Run("user object", func(t *testing.T) {
+		var message Packet
+		require.NoError(t, Unmarshal(
+			[]byte(`2["upload",{"base64":true,"data":"AQID","name":"a"},{"base64":true,"size":"!"},{"base64":false,"data":"!"}]`),
+			&message, WithBase64Binary(),
+		))
+		assert.Equal(t, []interface{}{
+			"upload",
+			map[string]interface{}{"base64": true, "data": "AQID", "name": "a"},
+			map[string]interface{}{"base64": true, "size": "!"},
+			map[string]interface{}{"base64": false, "data": "!"},
+		}, message.Data)
+	})
+}
+
+func TestUnmarshal_zeroCopy(t *testing.T) {
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var message Packet
+			require.NoError(t, Unmarshal([]byte(test.Tmpl), &message, WithZeroCopy()))
+			require.Equal(t, len(test.Data), len(message.Data))
+
+			assert.Equal(t, test.Header, message.Header)
+			for idx, data := range test.Data {
+				arg := message.Data[idx]
+				if raw, ok := arg.(json.RawMessage); ok {
+					require.NoError(t, readJSON(bytes.NewReader(raw), &arg))
+
+					var err error
+					arg, err = normalizeJSON(arg, false, false, nil)
+					require.NoError(t, err)
+				}
+
+				assert.Equal(t, data, arg)
+			}
+		})
+	}
+
+	t.Run("shares input", func(t *testing.T) {
+		data := []byte(`51-/upload,["file",{"_placeholder":true,"num":0},{"meta":1}]` + "\nabc")
+
+		var message Packet
+		require.NoError(t, Unmarshal(data, &message, WithZeroCopy(), WithValidation()))
+		assert.Equal(t, "/upload", message.Header.Namespace)
+		assert.Equal(t, []interface{}{
+			json.RawMessage(`"file"`),
+			&Buffer{IsBinary: true, Num: 0, Data: []byte("abc")},
+			json.RawMessage(`{"meta":1}`),
+		}, message.Data)
+
+		var (
+			name   string
+			buffer Buffer
+		)
+		require.NoError(t, message.DecodeArgs(&name, &buffer))
+		assert.Equal(t, "file", name)
+		assert.Equal(t, []byte("abc"), buffer.Data)
+
+		// the caller owns the input, changes of it are visible in the packet.
+		copy(data[4:], "UPLOAD")
+		data[len(data)-1] = 'C'
+		assert.Equal(t, "/UPLOAD", message.Header.Namespace)
+		assert.Equal(t, []byte("abC"), message.Data[1].(*Buffer).Data)
+	})
+
+	t.Run("copy by default", func(t *testing.T) {
+		data := []byte(`51-/upload,["file",{"_placeholder":true,"num":0}]` + "\nabc")
+
+		var message Packet
+		require.NoError(t, Unmarshal(data, &message))
+
+		copy(data[4:], "UPLOAD")
+		data[len(data)-1] = 'C'
+		assert.Equal(t, "/upload", message.Header.Namespace)
+		assert.Equal(t, []byte("abc"), message.Data[1].(*Buffer).Data)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		var message Packet
+		err := Unmarshal([]byte(`2[ "disconnect" ]`), &message, WithZeroCopy(), WithValidation())
+		assert.True(t, errors.Is(err, ErrInvalidPacket))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		var message Packet
+		err := Unmarshal([]byte(`2["a",}`), &message, WithZeroCopy())
+		assert.True(t, errors.Is(err, ErrInvalidPayload))
+
+		err = Unmarshal([]byte(`2["a",["b"]`), &message, WithZeroCopy())
+		assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+	})
+}
+
+func TestUnmarshalFrames(t *testing.T) {
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			frames := strings.Split(test.Tmpl, string('\n'))
+			var attachments [][]byte
+			for _, frame := range frames[1:] {
+				attachments = append(attachments, []byte(frame))
+			}
+
+			var message Packet
+			err := UnmarshalFrames(frames[0], attachments, &message)
+			require.NoError(t, err)
+			require.Equal(t, len(test.Data), len(message.Data))
+
+			assert.Equal(t, test.Header, message.Header)
+			assert.Equal(t, test.Payload, message.Payload)
+			for idx, data := range test.Data {
+				assert.Equal(t, data, message.Data[idx])
+			}
+		})
+	}
+
+	t.Run("reused attachments", func(t *testing.T) {
+		const text = `51-["a",{"_placeholder":true,"num":0}]`
+
+		attachments := [][]byte{{1, 2, 3}}
+		var message Packet
+		require.NoError(t, UnmarshalFrames(text, attachments, &message))
+		attachments[0][0] = 9
+		assert.Equal(t, []byte{1, 2, 3}, message.Data[1].(*Buffer).Data)
+
+		attachments = [][]byte{{1, 2, 3}}
+		require.NoError(t, UnmarshalFrames(text, attachments, &message, WithZeroCopy()))
+		attachments[0][0] = 9
+		assert.Equal(t, []byte{9, 2, 3}, message.Data[1].(*Buffer).Data)
+	})
+
+	t.Run("missing attachment", func(t *testing.T) {
+		var message Packet
+		err := UnmarshalFrames(`52-["msg",{"_placeholder":true,"num":0},{"_placeholder":true,"num":1}]`, [][]byte{{1}}, &message)
+		assert.Error(t, err)
+	})
+}
+
+func TestUnmarshal_json(t *testing.T) {
+	var message Packet
+	err := Unmarshal([]byte(`2["a,b","say \"hi\"","\u00e9",1.5,-2,true,false,null,[1,["x"]],{"k":{"n":3}},1e100]`), &message)
+	require.NoError(t, err)
+
+	assert.Equal(t, Header{Type: Event}, message.Header)
+	assert.Equal(t, []interface{}{
+		"a,b",
+		`say "hi"`,
+		"é",
+		1.5,
+		-2,
+		true,
+		false,
+		nil,
+		[]interface{}{1, []interface{}{"x"}},
+		map[string]interface{}{"k": map[string]interface{}{"n": 3}},
+		1e100,
+	}, message.Data)
+
+	t.Run("numbers", func(t *testing.T) {
+		var message Packet
+		require.NoError(t, Unmarshal([]byte(`2[1,1.0,1e2,-0,1e-400,1.5,1e20]`), &message))
+		assert.Equal(t, []interface{}{1, 1, 100, 0, 0, 1.5, 1e20}, message.Data)
+	})
+
+	t.Run("number out of range", func(t *testing.T) {
+		for _, data := range []string{`2[1e400]`, `2["a",{"b":[-1E+400]}]`, `0/admin,{"a":1e400}`, `2["` + strings.Repeat("9", 400) + `",1e400]`} {
+			for _, opts := range [][]Option{nil, {WithZeroCopy()}} {
+				var message Packet
+				err := Unmarshal([]byte(data), &message, opts...)
+
+				var decodeErr *DecodeError
+				require.True(t, errors.As(err, &decodeErr), "%s: %v", data, err)
+				assert.Equal(t, SectionPayload, decodeErr.Section)
+				assert.True(t, errors.Is(err, ErrInvalidPayload), data)
+			}
+		}
 
-		buf, err := readJSONPayload(r)
+		var message Packet
+		require.NoError(t, Unmarshal([]byte(`2["1e400",`+strings.Repeat("9", 300)+`]`), &message, WithZeroCopy()))
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		packet := &Packet{
+			Header: Header{Type: Event, Namespace: "/woot"},
+			Data: []interface{}{
+				"msg",
+				map[string]interface{}{"list": []interface{}{1, 2.5, "x,y"}, "ok": true},
+				nil,
+			},
+		}
+
+		resp, err := Marshal(packet)
 		require.NoError(t, err)
 
+		var message Packet
+		require.NoError(t, Unmarshal(resp, &message))
+
+		assert.Equal(t, packet, &message)
+	})
+
+	t.Run("unterminated", func(t *testing.T) {
+		var message Packet
+		assert.Error(t, Unmarshal([]byte(`2["msg",1`), &message))
+	})
+
+	t.Run("not array", func(t *testing.T) {
+		var message Packet
+		assert.Error(t, Unmarshal([]byte(`2{"msg":1}`), &message))
+	})
+
+	t.Run("ambiguous payload", func(t *testing.T) {
+		for _, data := range []string{`0-0`, `4-1.5`, `0 [0]`} {
+			var message Packet
+			err := Unmarshal([]byte(data), &message)
+			assert.True(t, errors.Is(err, ErrInvalidPayload), data)
+		}
+	})
+}
+
+func TestUnmarshal_placeholders(t *testing.T) {
+	data := []byte(`53-["upload",{"file":{"_placeholder":true,"num":2},"list":[{"_placeholder":true,"num":0}]},{"_placeholder":true,"num":1}]`)
+	data = append(data, '\n', 1, '\n', 2, '\n', 3, '\n', 4)
+
+	var message Packet
+	require.NoError(t, Unmarshal(data, &message))
+
+	assert.Equal(t, []interface{}{
+		"upload",
+		map[string]interface{}{
+			"file": &Buffer{IsBinary: true, Num: 2, Data: []byte{3, '\n', 4}},
+			"list": []interface{}{&Buffer{IsBinary: true, Num: 0, Data: []byte{1}}},
+		},
+		&Buffer{IsBinary: true, Num: 1, Data: []byte{2}},
+	}, message.Data)
+
+	t.Run("frames", func(t *testing.T) {
+		text := `52-["upload",[{"_placeholder":true,"num":1},{"_placeholder":true,"num":0}]]`
+
+		var message Packet
+		require.NoError(t, UnmarshalFrames(text, [][]byte{{1}, {2}}, &message))
+
+		assert.Equal(t, []interface{}{
+			"upload",
+			[]interface{}{
+				&Buffer{IsBinary: true, Num: 1, Data: []byte{2}},
+				&Buffer{IsBinary: true, Num: 0, Data: []byte{1}},
+			},
+		}, message.Data)
+	})
+
+	t.Run("args", func(t *testing.T) {
+		var (
+			event   string
+			payload struct {
+				File Buffer   `json:"file"`
+				List []Buffer `json:"list"`
+			}
+		)
+		require.NoError(t, message.DecodeArgs(&event, &payload))
+
+		assert.Equal(t, "upload", event)
+		assert.Equal(t, []byte{3, '\n', 4}, payload.File.Data)
+		require.Len(t, payload.List, 1)
+		assert.Equal(t, []byte{1}, payload.List[0].Data)
+	})
+}
+
+func TestUnmarshal_strictPlaceholders(t *testing.T) {
+	invalid := []struct {
+		name string
+		text string
+		err  error
+	}{
+		{"duplicate", `52-["a",{"_placeholder":true,"num":0},{"_placeholder":true,"num":0}]`, ErrInvalidPlaceholder},
+		{"negative", `51-["a",{"_placeholder":true,"num":-1}]`, ErrInvalidPlaceholder},
+		{"float", `51-["a",{"_placeholder":true,"num":0.5}]`, ErrInvalidPlaceholder},
+		{"string", `51-["a",{"_placeholder":true,"num":"0"}]`, ErrInvalidPlaceholder},
+		{"missing num", `51-["a",{"_placeholder":true}]`, ErrInvalidPlaceholder},
+		{"out of range", `51-["a",{"_placeholder":true,"num":1}]`, ErrInvalidPlaceholder},
+		{"huge num", `51-["a",{"_placeholder":true,"num":18446744073709551615}]`, ErrInvalidPlaceholder},
+		{"fewer placeholders", `52-["a",{"_placeholder":true,"num":0}]`, ErrIllegalAttachments},
+		{"more placeholders", `51-["a",{"_placeholder":true,"num":0},{"_placeholder":true,"num":1}]`, ErrIllegalAttachments},
+	}
+
+	for _, test := range invalid {
+		t.Run(test.name, func(t *testing.T) {
+			attachments := [][]byte{{1}, {2}}
+
+			var message Packet
+			err := UnmarshalFrames(test.text, attachments[:1], &message)
+			assert.True(t, errors.Is(err, test.err), "%v", err)
+
+			err = Unmarshal([]byte(test.text+"\n\x01\n\x02"), &message)
+			assert.True(t, errors.Is(err, test.err), "%v", err)
+		})
+	}
+
+	t.Run("without payload", func(t *testing.T) {
+		var message Packet
+		err := UnmarshalFrames(`51-/woot,1`, [][]byte{{1}}, &message)
+		assert.True(t, errors.Is(err, ErrIllegalAttachments), "%v", err)
+	})
+
+	t.Run("text packet", func(t *testing.T) {
+		var message Packet
+		require.NoError(t, Unmarshal([]byte(`2["a",{"_placeholder":true,"num":0}]`), &message))
+
+		assert.Equal(t, []interface{}{
+			"a",
+			map[string]interface{}{"_placeholder": true, "num": 0},
+		}, message.Data)
+	})
+}
+
+func Test_placeholder(t *testing.T) {
+	t.Run("empty json", func(t *testing.T) {
+		buf, ok, err := placeholder(map[string]interface{}{})
+		require.NoError(t, err)
+		assert.False(t, ok)
 		assert.Empty(t, buf)
 	})
+
+	t.Run("placeholder", func(t *testing.T) {
+		buf, ok, err := placeholder(map[string]interface{}{"_placeholder": true, "num": json.Number("2")})
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, &Buffer{IsBinary: true, Num: 2}, buf)
+	})
+
+	for _, num := range []interface{}{json.Number("-1"), json.Number("1.5"), json.Number("1e3"), "1", nil} {
+		t.Run(fmt.Sprintf("num %v", num), func(t *testing.T) {
+			_, ok, err := placeholder(map[string]interface{}{"_placeholder": true, "num": num})
+			assert.True(t, ok)
+			assert.Equal(t, ErrInvalidPlaceholder, err)
+		})
+	}
 }
 
 func Test_decodeData(t *testing.T) {
@@ -43,7 +455,7 @@ func Test_decodeData(t *testing.T) {
 
 		r := bytes.NewReader(data)
 
-		decodedData, err := decodeData(r)
+		decodedData, err := decodeData(r, data, true, 2, options{})
 		require.Error(t, err, "not found binary attachments")
 
 		assert.Empty(t, decodedData)
@@ -62,7 +474,7 @@ func Test_decodeData(t *testing.T) {
 
 		r := bytes.NewReader(data)
 
-		decodedData, err := decodeData(r)
+		decodedData, err := decodeData(r, data, true, 2, options{})
 		require.NoError(t, err)
 		require.Len(t, decodedData, 4)
 
@@ -86,3 +498,23 @@ func BenchmarkUnmarshal(b *testing.B) {
 		_ = Unmarshal(data, &message)
 	}
 }
+
+func BenchmarkUnmarshal_zeroCopy(b *testing.B) {
+	data := []byte(`51-/woot,1["msg",{"_placeholder":true,"num":0}]` + string('\n') + string(make([]byte, 64<<10)))
+
+	for _, bench := range []struct {
+		name string
+		opts []Option
+	}{
+		{"copy", nil},
+		{"zero copy", []Option{WithZeroCopy()}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var message Packet
+				_ = Unmarshal(data, &message, bench.opts...)
+			}
+		})
+	}
+}
@@ -1,131 +1,232 @@
 package go_socketio_parser
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/json"
 	"errors"
-	"io"
-	"io/ioutil"
+	"fmt"
 	"reflect"
+	"sync"
 )
 
 const brByte = byte('\n')
 
-// Marshal packet header with request payload.
-func Marshal(packet *Packet) ([]byte, error) {
+// Marshal packet header with request payload. Every binary attachment is
+// appended after '\n' separator, so only the last attachment may contain it:
+// ErrAttachmentSeparator is returned otherwise. Use MarshalFrames or Encoder
+// for arbitrary binary data.
+func Marshal(packet *Packet, opts ...Option) ([]byte, error) {
 	if packet == nil {
 		return nil, errors.New("empty packet source")
 	}
 
-	buf := bytes.NewBuffer(nil)
-	bw := bufio.NewWriter(buf)
-
-	buffers, err := writePacket(bw, packet.Header, packet.Data)
+	buf, buffers, err := appendPacket(nil, packet, newOptions(opts))
 	if err != nil {
 		return nil, err
 	}
 
+	// separator inside attachment can't be told apart from the next one.
+	for i := 0; i < len(buffers)-1; i++ {
+		if bytes.IndexByte(buffers[i], brByte) >= 0 {
+			return nil, ErrAttachmentSeparator
+		}
+	}
+
 	// write binary data.
-	if len(buffers) > 0 {
-		_ = bw.WriteByte(brByte)
+	for _, b := range buffers {
+		buf = append(buf, brByte)
+		buf = append(buf, b...)
+	}
 
-		for _, b := range buffers {
-			_, err = bw.Write(b)
-			if err != nil {
-				return nil, err
-			}
-		}
+	return buf, nil
+}
+
+// MarshalFrames returns packet as the text frame and the binary attachments
+// frames, in the same shape as they are sent by transport.
+func MarshalFrames(packet *Packet, opts ...Option) (string, [][]byte, error) {
+	if packet == nil {
+		return "", nil, errors.New("empty packet source")
 	}
 
-	_ = bw.Flush()
+	buf, buffers, err := appendPacket(nil, packet, newOptions(opts))
+	if err != nil {
+		return "", nil, err
+	}
 
-	return ioutil.ReadAll(buf)
+	return string(buf), buffers, nil
 }
 
-type byteWriter interface {
-	io.Writer
-	WriteByte(byte) error
+// AppendPacket appends the text frame of packet to dst and returns the
+// extended buffer and the binary attachments frames. Reusing dst between
+// calls avoids allocations of the frame, packets without payload and with
+// typed payload without buffers are encoded without allocations. Interface
+// values and maps are allocated by encoding/json, buffers cost the copies of
+// the values on the path to them and the attachments list.
+func AppendPacket(dst []byte, packet *Packet, opts ...Option) ([]byte, [][]byte, error) {
+	if packet == nil {
+		return dst, nil, errors.New("empty packet source")
+	}
+
+	return appendPacket(dst, packet, newOptions(opts))
 }
 
 const binaryTypeShift = 3
 
-func writePacket(bw byteWriter, h Header, data []interface{}) ([][]byte, error) {
-	var max uint64
-	buffers, err := attachBuffer(reflect.ValueOf(data), &max)
-	if err != nil {
-		return nil, err
+func appendPacket(dst []byte, packet *Packet, o options) ([]byte, [][]byte, error) {
+	if err := o.check(packet); err != nil {
+		return dst, nil, err
+	}
+
+	h := packet.Header
+	hasData := packet.Data != nil
+
+	// only CONNECT and CONNECT_ERROR carry single payload instead of arguments.
+	if packet.Payload != nil && ((h.Type != Connect && h.Type != Error) || hasData) {
+		return dst, nil, fmt.Errorf("%w: payload is allowed only without data in CONNECT and CONNECT_ERROR", ErrInvalidPacket)
+	}
+
+	// protocol v4 CONNECT packet has no payload.
+	if o.protocol == ProtocolV4 && h.Type == Connect && (hasData || packet.Payload != nil) {
+		return dst, nil, fmt.Errorf("%w: CONNECT should not have payload in protocol v4", ErrInvalidPacket)
+	}
+
+	// buffers are replaced inside a copy of data, so packet can be shared by
+	// concurrent encoders. Pointer to data is not boxed into interface, so it
+	// isn't allocated.
+	enc := bufferEncoder{base64: o.base64}
+	data := &packet.Data
+	if v, ok := enc.replace(reflect.ValueOf(data).Elem()); ok {
+		args := v.Interface().([]interface{})
+		data = &args
+	}
+	if enc.err != nil {
+		return dst, nil, enc.err
 	}
+	buffers := enc.buffers
 
 	// if client send data, but use Event or Ack we will upgrade header type to binary.
 	if len(buffers) > 0 && (h.Type == Event || h.Type == Ack) {
 		h.Type += binaryTypeShift
 	}
 
+	start := len(dst)
+
 	// packet type
-	if err = bw.WriteByte(byte(h.Type + '0')); err != nil {
-		return nil, err
-	}
+	dst = append(dst, byte(h.Type+'0'))
 
 	// type of binary attachments with '-'
 	if h.Type == BinaryAck || h.Type == BinaryEvent {
-		if err = writeUint64(bw, max); err != nil {
-			return nil, err
-		}
+		dst = appendUint64(dst, uint64(len(buffers)))
+		dst = append(dst, binarySep)
+	}
 
-		if err = bw.WriteByte('-'); err != nil {
-			return nil, err
-		}
+	// namespace, protocol v4 CONNECT appends query to it. Default namespace
+	// of protocol v4 is implicit, it is written only before query.
+	query := o.protocol == ProtocolV4 && h.Type == Connect && h.Query != ""
+	if o.protocol == ProtocolV4 && h.Namespace == defaultNamespace {
+		h.Namespace = ""
+	}
+	if query && h.Namespace == "" {
+		h.Namespace = defaultNamespace
 	}
 
-	// namespace
 	if h.Namespace != "" {
-		if _, err = bw.Write([]byte(h.Namespace)); err != nil {
-			return nil, err
+		dst = append(dst, h.Namespace...)
+		if query {
+			dst = append(dst, querySep)
+			dst = append(dst, h.Query...)
 		}
 
-		if h.ID != 0 || data != nil {
-			if err = bw.WriteByte(','); err != nil {
-				return nil, err
-			}
+		if h.IsNeedAck() || hasData || packet.Payload != nil {
+			dst = append(dst, nsEndSep)
 		}
 	}
 
 	//acknowledgment id
 	if h.IsNeedAck() {
-		if err = writeUint64(bw, h.ID); err != nil {
-			return nil, err
-		}
+		dst = appendUint64(dst, h.ID)
 	}
 
 	// JSON-stringified payload without binary
-	if data != nil {
-		jsonData, err := json.Marshal(data)
-		if err != nil {
-			return nil, err
+	var payload interface{}
+	if packet.Payload != nil {
+		payload = packet.Payload
+	} else if hasData {
+		payload = data
+	}
+
+	if payload != nil {
+		offset := len(dst)
+
+		var err error
+		if dst, err = appendJSON(dst, payload); err != nil {
+			return dst[:start], nil, err
 		}
 
-		if _, err = bw.Write(jsonData); err != nil {
-			return nil, err
+		// number payload would be read back as acknowledgment id and array
+		// as arguments.
+		if packet.Payload != nil && len(dst) > offset {
+			if b := dst[offset]; isNumberByte(b) || b == '-' || b == dataOpenSep {
+				return dst[:start], nil, fmt.Errorf("%w: payload should not be a number or an array", ErrInvalidPacket)
+			}
 		}
 	}
 
-	return buffers, nil
+	return dst, buffers, nil
 }
 
-func writeUint64(w byteWriter, i uint64) error {
+// jsonAppender appends JSON encoding to the buffer. It is pooled with its
+// json.Encoder, which writes output directly without a copy of its own.
+type jsonAppender struct {
+	buf []byte
+	enc *json.Encoder
+}
+
+func (a *jsonAppender) Write(p []byte) (int, error) {
+	a.buf = append(a.buf, p...)
+
+	return len(p), nil
+}
+
+var jsonAppenderPool = sync.Pool{
+	New: func() interface{} {
+		a := &jsonAppender{}
+		a.enc = json.NewEncoder(a)
+
+		return a
+	},
+}
+
+// appendJSON appends JSON encoding of v to dst, the same as json.Marshal returns.
+func appendJSON(dst []byte, v interface{}) ([]byte, error) {
+	a := jsonAppenderPool.Get().(*jsonAppender)
+	a.buf = dst
+
+	err := a.enc.Encode(v)
+	dst = a.buf
+
+	a.buf = nil
+	jsonAppenderPool.Put(a)
+
+	if err != nil {
+		return dst, err
+	}
+
+	// json.Encoder terminates every value by newline.
+	return dst[:len(dst)-1], nil
+}
+
+func appendUint64(dst []byte, i uint64) []byte {
 	base := uint64(1)
 	for i/base >= 10 {
 		base *= 10
 	}
 	for base > 0 {
 		p := i / base
-		if err := w.WriteByte(byte(p) + '0'); err != nil {
-			return err
-		}
+		dst = append(dst, byte(p)+'0')
 		i -= p * base
 		base /= 10
 	}
 
-	return nil
+	return dst
 }
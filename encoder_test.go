@@ -1,6 +1,12 @@
 package go_socketio_parser
 
 import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -11,8 +17,9 @@ func TestEncoder(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.Name, func(t *testing.T) {
 			packet := &Packet{
-				Header: test.Header,
-				Data:   test.Data,
+				Header:  test.Header,
+				Data:    test.Data,
+				Payload: test.Payload,
 			}
 
 			resp, err := Marshal(packet)
@@ -23,6 +30,330 @@ func TestEncoder(t *testing.T) {
 	}
 }
 
+func TestMarshal_protocolV4(t *testing.T) {
+	for _, test := range testsV4 {
+		t.Run(test.Name, func(t *testing.T) {
+			packet := &Packet{
+				Header:  test.Header,
+				Data:    test.Data,
+				Payload: test.Payload,
+			}
+
+			resp, err := Marshal(packet, WithProtocolVersion(ProtocolV4), WithValidation())
+			require.NoError(t, err)
+
+			assert.Equal(t, test.Tmpl, string(resp))
+		})
+	}
+
+	t.Run("connect payload", func(t *testing.T) {
+		packet := &Packet{
+			Header:  Header{Type: Connect},
+			Payload: map[string]interface{}{"token": "abc"},
+		}
+
+		_, err := Marshal(packet, WithProtocolVersion(ProtocolV4))
+		assert.True(t, errors.Is(err, ErrInvalidPacket))
+	})
+
+	t.Run("query without nsp", func(t *testing.T) {
+		resp, err := Marshal(&Packet{Header: Header{Type: Connect, Query: "a=1"}}, WithProtocolVersion(ProtocolV4))
+		require.NoError(t, err)
+		assert.Equal(t, "0/?a=1", string(resp))
+	})
+
+	t.Run("default nsp", func(t *testing.T) {
+		for _, test := range []struct {
+			packet *Packet
+			tmpl   string
+		}{
+			{&Packet{Header: Header{Type: Connect, Namespace: "/"}}, "0"},
+			{&Packet{Header: Header{Type: Event, Namespace: "/", ID: 1, HasID: true}, Data: []interface{}{"msg"}}, `21["msg"]`},
+			{&Packet{Header: Header{Type: Connect, Namespace: "/", Query: "a=1"}}, "0/?a=1"},
+		} {
+			resp, err := Marshal(test.packet, WithProtocolVersion(ProtocolV4))
+			require.NoError(t, err)
+			assert.Equal(t, test.tmpl, string(resp))
+		}
+
+		resp, err := Marshal(&Packet{Header: Header{Type: Connect, Namespace: "/"}})
+		require.NoError(t, err)
+		assert.Equal(t, "0/", string(resp))
+	})
+
+	t.Run("query in protocol v5", func(t *testing.T) {
+		_, err := Marshal(&Packet{Header: Header{Type: Connect, Query: "a=1"}}, WithValidation())
+		assert.True(t, errors.Is(err, ErrInvalidPacket))
+	})
+}
+
+func TestMarshal_base64Binary(t *testing.T) {
+	packet := &Packet{
+		Header: Header{
+			Type: Event,
+		},
+		Data: []interface{}{
+			"upload",
+			&Buffer{Data: []byte{1, 2, 3}},
+			map[string]interface{}{"file": &Buffer{Data: []byte("hi")}},
+		},
+	}
+
+	text, attachments, err := MarshalFrames(packet, WithBase64Binary())
+	require.NoError(t, err)
+	assert.Empty(t, attachments)
+	assert.Equal(t, `2["upload",{"base64":true,"data":"AQID"},{"file":{"base64":true,"data":"aGk="}}]`, text)
+
+	// buffer marked by binary encoding earlier is inlined too.
+	_, err = Marshal(packet)
+	require.NoError(t, err)
+
+	text, _, err = MarshalFrames(packet, WithBase64Binary())
+	require.NoError(t, err)
+	assert.Equal(t, `2["upload",{"base64":true,"data":"AQID"},{"file":{"base64":true,"data":"aGk="}}]`, text)
+}
+
+func TestMarshalFrames(t *testing.T) {
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			packet := &Packet{
+				Header:  test.Header,
+				Data:    test.Data,
+				Payload: test.Payload,
+			}
+
+			text, attachments, err := MarshalFrames(packet)
+			require.NoError(t, err)
+
+			frames := strings.Split(test.Tmpl, string('\n'))
+			assert.Equal(t, frames[0], text)
+			require.Len(t, attachments, len(frames)-1)
+			for idx, attachment := range attachments {
+				assert.Equal(t, frames[idx+1], string(attachment))
+			}
+		})
+	}
+
+	t.Run("nil packet", func(t *testing.T) {
+		_, _, err := MarshalFrames(nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestAppendPacket(t *testing.T) {
+	prefix := []byte("prefix")
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			packet := &Packet{
+				Header:  test.Header,
+				Data:    test.Data,
+				Payload: test.Payload,
+			}
+
+			buf, attachments, err := AppendPacket(prefix[:len(prefix):len(prefix)], packet)
+			require.NoError(t, err)
+
+			frames := strings.Split(test.Tmpl, string('\n'))
+			assert.Equal(t, "prefix"+frames[0], string(buf))
+			require.Len(t, attachments, len(frames)-1)
+		})
+	}
+
+	t.Run("error keeps dst", func(t *testing.T) {
+		buf, _, err := AppendPacket(prefix, &Packet{Header: Header{Type: Error}, Payload: 1})
+		assert.True(t, errors.Is(err, ErrInvalidPacket))
+		assert.Equal(t, prefix, buf)
+	})
+
+	t.Run("allocations", func(t *testing.T) {
+		packet := &Packet{Header: Header{Type: Disconnect, Namespace: "/admin", ID: 12, HasID: true}}
+		buf := make([]byte, 0, 64)
+
+		allocs := testing.AllocsPerRun(100, func() {
+			buf, _, _ = AppendPacket(buf[:0], packet)
+		})
+		assert.Zero(t, allocs)
+		assert.Equal(t, "1/admin,12", string(buf))
+	})
+
+	t.Run("allocations of typed payload", func(t *testing.T) {
+		if raceEnabled {
+			t.Skip("sync.Pool drops values with race detector")
+		}
+
+		packet := &Packet{Header: Header{Type: Connect, Namespace: "/admin"}, Payload: &benchRow{ID: 1, Name: "a"}}
+		buf := make([]byte, 0, 64)
+
+		allocs := testing.AllocsPerRun(100, func() {
+			buf, _, _ = AppendPacket(buf[:0], packet)
+		})
+		assert.Zero(t, allocs)
+		assert.Equal(t, `0/admin,{"id":1,"name":"a","tags":null,"attrs":null}`, string(buf))
+	})
+}
+
+func TestMarshal_attachments(t *testing.T) {
+	packet := &Packet{
+		Header: Header{
+			Type: Event,
+		},
+		Data: []interface{}{
+			"msg",
+			&Buffer{Data: []byte{1, 2}},
+			&Buffer{Data: []byte{3, 4}},
+		},
+	}
+
+	resp, err := Marshal(packet)
+	require.NoError(t, err)
+
+	assert.Equal(t, `52-["msg",{"_placeholder":true,"num":0},{"_placeholder":true,"num":1}]`+
+		string([]byte{'\n', 1, 2, '\n', 3, 4}), string(resp))
+}
+
+func TestMarshal_attachmentSeparator(t *testing.T) {
+	t.Run("last attachment", func(t *testing.T) {
+		packet := &Packet{
+			Header: Header{Type: Event},
+			Data:   []interface{}{"msg", &Buffer{Data: []byte{1}}, &Buffer{Data: []byte{'\n', 2, '\n'}}},
+		}
+
+		data, err := Marshal(packet)
+		require.NoError(t, err)
+
+		var message Packet
+		require.NoError(t, Unmarshal(data, &message))
+		assert.Equal(t, []interface{}{
+			"msg",
+			&Buffer{IsBinary: true, Num: 0, Data: []byte{1}},
+			&Buffer{IsBinary: true, Num: 1, Data: []byte{'\n', 2, '\n'}},
+		}, message.Data)
+	})
+
+	t.Run("followed by attachment", func(t *testing.T) {
+		packet := &Packet{
+			Header: Header{Type: Event},
+			Data:   []interface{}{"msg", &Buffer{Data: []byte{1, '\n', 2}}, &Buffer{Data: []byte{3}}},
+		}
+
+		_, err := Marshal(packet)
+		assert.Equal(t, ErrAttachmentSeparator, err)
+
+		// frames keep any data.
+		text, attachments, err := MarshalFrames(packet)
+		require.NoError(t, err)
+
+		var message Packet
+		require.NoError(t, UnmarshalFrames(text, attachments, &message))
+		assert.Equal(t, []interface{}{
+			"msg",
+			&Buffer{IsBinary: true, Num: 0, Data: []byte{1, '\n', 2}},
+			&Buffer{IsBinary: true, Num: 1, Data: []byte{3}},
+		}, message.Data)
+	})
+}
+
+func TestMarshal_payload(t *testing.T) {
+	resp, err := Marshal(&Packet{
+		Header:  Header{Type: Connect, Namespace: "/admin"},
+		Payload: &ConnectPayload{SID: "abc"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, `0/admin,{"sid":"abc"}`, string(resp))
+
+	t.Run("ambiguous", func(t *testing.T) {
+		for _, payload := range []interface{}{0, -1, []int{1}} {
+			_, err := Marshal(&Packet{Header: Header{Type: Error}, Payload: payload})
+			assert.True(t, errors.Is(err, ErrInvalidPacket), payload)
+		}
+	})
+
+	t.Run("not single payload packet", func(t *testing.T) {
+		for name, packet := range map[string]*Packet{
+			"event":        {Header: Header{Type: Event}, Payload: map[string]int{"x": 1}},
+			"event data":   {Header: Header{Type: Event}, Data: []interface{}{"a"}, Payload: map[string]int{"x": 1}},
+			"empty data":   {Header: Header{Type: Event}, Data: []interface{}{}, Payload: map[string]int{"x": 1}},
+			"binary event": {Header: Header{Type: BinaryEvent}, Payload: &Buffer{Data: []byte{1}}},
+			"ack":          {Header: Header{Type: Ack, ID: 1, HasID: true}, Payload: map[string]int{"x": 1}},
+			"disconnect":   {Header: Header{Type: Disconnect}, Payload: "bye"},
+			"connect data": {Header: Header{Type: Connect}, Data: []interface{}{"a"}, Payload: map[string]int{"x": 1}},
+			"error data":   {Header: Header{Type: Error}, Data: []interface{}{"a"}, Payload: "denied"},
+		} {
+			_, err := Marshal(packet)
+			assert.True(t, errors.Is(err, ErrInvalidPacket), "%s: %v", name, err)
+		}
+
+		_, err := Marshal(&Packet{Header: Header{Type: Error}, Payload: "denied"})
+		assert.NoError(t, err)
+	})
+}
+
+func TestMarshal_cycle(t *testing.T) {
+	data := map[string]interface{}{}
+	data["b"] = data
+
+	_, err := Marshal(&Packet{Header: Header{Type: Event}, Data: []interface{}{"a", data}})
+
+	var cycleErr *json.UnsupportedValueError
+	assert.True(t, errors.As(err, &cycleErr))
+}
+
+func TestMarshal_concurrent(t *testing.T) {
+	shared := &Buffer{Data: []byte{3}}
+	packet := &Packet{
+		Header: Header{Type: Event, Namespace: "/chat"},
+		Data: []interface{}{
+			"upload",
+			Buffer{Data: []byte{1}},
+			map[string]interface{}{"file": shared},
+			bufferStruct{I: 1, Buffer: shared},
+			[]Buffer{{Data: []byte{2}}},
+		},
+	}
+	other := &Packet{
+		Header: Header{Type: Event},
+		Data:   []interface{}{"other", shared},
+	}
+
+	const text = `54-/chat,["upload",{"_placeholder":true,"num":0},{"file":{"_placeholder":true,"num":1}},` +
+		`{"i":1,"buf":{"_placeholder":true,"num":2}},[{"_placeholder":true,"num":3}]]`
+	const base64Text = `2/chat,["upload",{"base64":true,"data":"AQ=="},{"file":{"base64":true,"data":"Aw=="}},` +
+		`{"i":1,"buf":{"base64":true,"data":"Aw=="}},[{"base64":true,"data":"Ag=="}]]`
+	attachments := [][]byte{{1}, {3}, {3}, {2}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 50; j++ {
+				frame, buffers, err := MarshalFrames(packet)
+				assert.NoError(t, err)
+				assert.Equal(t, text, frame)
+				assert.Equal(t, attachments, buffers)
+
+				frame, buffers, err = MarshalFrames(packet, WithBase64Binary())
+				assert.NoError(t, err)
+				assert.Equal(t, base64Text, frame)
+				assert.Empty(t, buffers)
+
+				frame, buffers, err = MarshalFrames(other)
+				assert.NoError(t, err)
+				assert.Equal(t, `51-["other",{"_placeholder":true,"num":0}]`, frame)
+				assert.Equal(t, [][]byte{{3}}, buffers)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, &Buffer{Data: []byte{3}}, shared)
+	assert.Equal(t, Buffer{Data: []byte{1}}, packet.Data[1])
+	assert.Equal(t, []Buffer{{Data: []byte{2}}}, packet.Data[4])
+}
+
 func BenchmarkMarshal(b *testing.B) {
 	message := &Packet{
 		Header: Header{
@@ -44,3 +375,78 @@ func BenchmarkMarshal(b *testing.B) {
 		_, _ = Marshal(message)
 	}
 }
+
+func BenchmarkAppendPacket(b *testing.B) {
+	message := &Packet{
+		Header: Header{
+			Type:      Event,
+			ID:        1,
+			Namespace: "/woot",
+		},
+		Data: []interface{}{
+			"msg",
+			&Buffer{
+				IsBinary: true,
+				Data:     []byte{2, 3, 4},
+			},
+		},
+	}
+
+	var buf []byte
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf, _, _ = AppendPacket(buf[:0], message)
+	}
+}
+
+type benchRow struct {
+	ID    int               `json:"id"`
+	Name  string            `json:"name"`
+	Tags  []string          `json:"tags"`
+	Attrs map[string]string `json:"attrs"`
+}
+
+func BenchmarkMarshal_plainPayload(b *testing.B) {
+	ints := make([]int, 10000)
+	rows := make([]benchRow, 1000)
+	for i := range rows {
+		rows[i] = benchRow{ID: i, Name: "row", Tags: []string{"a", "b"}, Attrs: map[string]string{"k": "v"}}
+	}
+	dict := make(map[string]string, 1000)
+	for i := 0; i < 1000; i++ {
+		dict[strconv.Itoa(i)] = "value"
+	}
+
+	for _, bench := range []struct {
+		name string
+		data interface{}
+	}{
+		{"ints", ints},
+		{"structs", rows},
+		{"map", dict},
+	} {
+		message := &Packet{
+			Header: Header{Type: Event},
+			Data:   []interface{}{"msg", bench.data},
+		}
+
+		b.Run(bench.name, func(b *testing.B) {
+			b.Run("attach", func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					var enc bufferEncoder
+					_, _ = enc.replace(reflect.ValueOf(&message.Data).Elem())
+				}
+			})
+
+			b.Run("marshal", func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					_, _ = Marshal(message)
+				}
+			})
+		})
+	}
+}
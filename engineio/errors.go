@@ -0,0 +1,16 @@
+package engineio
+
+import "errors"
+
+var (
+	// ErrInvalidPacketType type
+	ErrInvalidPacketType = errors.New("invalid engine.io packet type")
+	// ErrEmptyPacket is returned for text frame without packet type.
+	ErrEmptyPacket = errors.New("empty engine.io packet")
+	// ErrBinaryPacket is returned for binary data in packet other than Message.
+	ErrBinaryPacket = errors.New("only message engine.io packet can be binary")
+	// ErrInvalidPayload is returned for malformed HTTP long-polling payload.
+	ErrInvalidPayload = errors.New("invalid engine.io payload")
+	// ErrNotMessage is returned when socket.io packet is decoded from packet other than Message.
+	ErrNotMessage = errors.New("engine.io packet is not a message")
+)
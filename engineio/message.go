@@ -0,0 +1,54 @@
+package engineio
+
+import (
+	parser "github.com/sshaplygin/go-socket.io-parser"
+)
+
+// MarshalMessage encodes socket.io packet into Engine.IO Message packets: the
+// text one followed by binary one for every attachment.
+func MarshalMessage(message *parser.Packet, opts ...parser.Option) ([]*Packet, error) {
+	text, attachments, err := parser.MarshalFrames(message, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	packets := make([]*Packet, 0, len(attachments)+1)
+	packets = append(packets, &Packet{
+		Type: Message,
+		Data: []byte(text),
+	})
+
+	for _, attachment := range attachments {
+		packets = append(packets, &Packet{
+			Type:     Message,
+			Data:     attachment,
+			IsBinary: true,
+		})
+	}
+
+	return packets, nil
+}
+
+// UnmarshalMessage decodes socket.io packet from Engine.IO text frame and the
+// binary frames of its attachments, as they are received by websocket.
+func UnmarshalMessage(frame []byte, attachments [][]byte, message *parser.Packet, opts ...parser.Option) error {
+	var packet Packet
+	if err := Unmarshal(parser.TextFrame, frame, &packet); err != nil {
+		return err
+	}
+
+	if packet.Type != Message {
+		return ErrNotMessage
+	}
+
+	return parser.UnmarshalFrames(string(packet.Data), attachments, message, opts...)
+}
+
+// Decode decodes socket.io packet carried by Message packet without attachments.
+func (p *Packet) Decode(message *parser.Packet, opts ...parser.Option) error {
+	if p.Type != Message || p.IsBinary {
+		return ErrNotMessage
+	}
+
+	return parser.UnmarshalFrames(string(p.Data), nil, message, opts...)
+}
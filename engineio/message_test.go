@@ -0,0 +1,72 @@
+package engineio
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	parser "github.com/sshaplygin/go-socket.io-parser"
+)
+
+func TestMarshalMessage(t *testing.T) {
+	packets, err := MarshalMessage(&parser.Packet{
+		Header: parser.Header{Type: parser.Event, Namespace: "/woot"},
+		Data:   []interface{}{"msg", &parser.Buffer{Data: []byte{1, 2}}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []*Packet{
+		{Type: Message, Data: []byte(`51-/woot,["msg",{"_placeholder":true,"num":0}]`)},
+		{Type: Message, Data: []byte{1, 2}, IsBinary: true},
+	}, packets)
+
+	_, frame, err := Marshal(packets[0])
+	require.NoError(t, err)
+	assert.Equal(t, `451-/woot,["msg",{"_placeholder":true,"num":0}]`, string(frame))
+}
+
+func TestUnmarshalMessage(t *testing.T) {
+	var message parser.Packet
+	err := UnmarshalMessage([]byte(`451-/woot,["msg",{"_placeholder":true,"num":0}]`), [][]byte{{1, 2}}, &message)
+	require.NoError(t, err)
+
+	assert.Equal(t, parser.Header{Type: parser.Event, Namespace: "/woot"}, message.Header)
+	assert.Equal(t, []interface{}{
+		"msg",
+		&parser.Buffer{IsBinary: true, Data: []byte{1, 2}},
+	}, message.Data)
+
+	t.Run("reused attachments", func(t *testing.T) {
+		attachments := [][]byte{{1, 2}}
+		var message parser.Packet
+		require.NoError(t, UnmarshalMessage([]byte(`451-["msg",{"_placeholder":true,"num":0}]`), attachments, &message))
+		attachments[0][0] = 9
+		assert.Equal(t, []byte{1, 2}, message.Data[1].(*parser.Buffer).Data)
+	})
+
+	t.Run("not message", func(t *testing.T) {
+		var message parser.Packet
+		assert.Equal(t, ErrNotMessage, UnmarshalMessage([]byte(`2probe`), nil, &message))
+	})
+
+	t.Run("options", func(t *testing.T) {
+		var message parser.Packet
+		err := UnmarshalMessage([]byte(`42[null]`), nil, &message, parser.WithValidation())
+		assert.True(t, errors.Is(err, parser.ErrInvalidPacket))
+	})
+}
+
+func TestPacket_Decode(t *testing.T) {
+	var packet Packet
+	require.NoError(t, Unmarshal(parser.TextFrame, []byte(`42/admin,1["project:delete",123]`), &packet))
+
+	var message parser.Packet
+	require.NoError(t, packet.Decode(&message))
+
+	assert.Equal(t, parser.Header{Type: parser.Event, Namespace: "/admin", ID: 1, HasID: true}, message.Header)
+	assert.Equal(t, []interface{}{"project:delete", 123}, message.Data)
+
+	assert.Equal(t, ErrNotMessage, (&Packet{Type: Ping}).Decode(&message))
+}
@@ -0,0 +1,90 @@
+package engineio
+
+import (
+	"encoding/json"
+	"errors"
+
+	parser "github.com/sshaplygin/go-socket.io-parser"
+)
+
+const zeroNumberByte = byte('0')
+
+// Marshal packet into transport frame. Binary Message packet is sent in binary
+// frame as is, others are sent in text frame prefixed by packet type.
+func Marshal(packet *Packet) (parser.FrameType, []byte, error) {
+	if packet == nil {
+		return 0, nil, errors.New("empty packet source")
+	}
+	if !packet.Type.IsValid() {
+		return 0, nil, ErrInvalidPacketType
+	}
+
+	if packet.IsBinary {
+		if packet.Type != Message {
+			return 0, nil, ErrBinaryPacket
+		}
+
+		return parser.BinaryFrame, packet.Data, nil
+	}
+
+	frame := make([]byte, 0, len(packet.Data)+1)
+	frame = append(frame, byte(packet.Type)+zeroNumberByte)
+	frame = append(frame, packet.Data...)
+
+	return parser.TextFrame, frame, nil
+}
+
+// Unmarshal packet from transport frame. Binary frame is always a Message packet.
+func Unmarshal(ft parser.FrameType, frame []byte, packet *Packet) error {
+	if packet == nil {
+		return errors.New("empty output packet destination")
+	}
+
+	if ft == parser.BinaryFrame {
+		*packet = Packet{
+			Type:     Message,
+			Data:     append([]byte(nil), frame...),
+			IsBinary: true,
+		}
+
+		return nil
+	}
+
+	if len(frame) == 0 {
+		return ErrEmptyPacket
+	}
+
+	t := Type(frame[0] - zeroNumberByte)
+	if !t.IsValid() {
+		return ErrInvalidPacketType
+	}
+
+	*packet = Packet{
+		Type: t,
+		Data: append([]byte(nil), frame[1:]...),
+	}
+
+	return nil
+}
+
+// NewOpen returns Open packet with handshake payload.
+func NewOpen(payload *OpenPayload) (*Packet, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Packet{
+		Type: Open,
+		Data: data,
+	}, nil
+}
+
+// DecodeOpen stores handshake payload of Open packet in v.
+func (p *Packet) DecodeOpen(v *OpenPayload) error {
+	if p.Type != Open {
+		return ErrInvalidPacketType
+	}
+
+	return json.Unmarshal(p.Data, v)
+}
@@ -0,0 +1,135 @@
+package engineio
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	parser "github.com/sshaplygin/go-socket.io-parser"
+)
+
+type testCase struct {
+	Name      string
+	Packet    Packet
+	FrameType parser.FrameType
+	Frame     string
+}
+
+var tests = []testCase{
+	{
+		Name:   "open",
+		Packet: Packet{Type: Open, Data: []byte(`{"sid":"lv_VI97HAXpY6yYWAAAC"}`)},
+		Frame:  `0{"sid":"lv_VI97HAXpY6yYWAAAC"}`,
+	},
+	{
+		Name:   "close",
+		Packet: Packet{Type: Close},
+		Frame:  "1",
+	},
+	{
+		Name:   "ping",
+		Packet: Packet{Type: Ping},
+		Frame:  "2",
+	},
+	{
+		Name:   "ping probe",
+		Packet: Packet{Type: Ping, Data: []byte("probe")},
+		Frame:  "2probe",
+	},
+	{
+		Name:   "pong probe",
+		Packet: Packet{Type: Pong, Data: []byte("probe")},
+		Frame:  "3probe",
+	},
+	{
+		Name:   "message",
+		Packet: Packet{Type: Message, Data: []byte("hello")},
+		Frame:  "4hello",
+	},
+	{
+		Name:      "binary message",
+		Packet:    Packet{Type: Message, Data: []byte{1, 2, 3}, IsBinary: true},
+		FrameType: parser.BinaryFrame,
+		Frame:     string([]byte{1, 2, 3}),
+	},
+	{
+		Name:   "upgrade",
+		Packet: Packet{Type: Upgrade},
+		Frame:  "5",
+	},
+	{
+		Name:   "noop",
+		Packet: Packet{Type: Noop},
+		Frame:  "6",
+	},
+}
+
+func TestMarshal(t *testing.T) {
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			ft, frame, err := Marshal(&test.Packet)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.FrameType, ft)
+			assert.Equal(t, test.Frame, string(frame))
+		})
+	}
+
+	t.Run("binary ping", func(t *testing.T) {
+		_, _, err := Marshal(&Packet{Type: Ping, IsBinary: true})
+		assert.Equal(t, ErrBinaryPacket, err)
+	})
+
+	t.Run("invalid type", func(t *testing.T) {
+		_, _, err := Marshal(&Packet{Type: Noop + 1})
+		assert.Equal(t, ErrInvalidPacketType, err)
+	})
+}
+
+func TestUnmarshal(t *testing.T) {
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var packet Packet
+			require.NoError(t, Unmarshal(test.FrameType, []byte(test.Frame), &packet))
+
+			assert.Equal(t, test.Packet, packet)
+		})
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		var packet Packet
+		assert.Equal(t, ErrEmptyPacket, Unmarshal(parser.TextFrame, nil, &packet))
+	})
+
+	t.Run("invalid type", func(t *testing.T) {
+		var packet Packet
+		assert.Equal(t, ErrInvalidPacketType, Unmarshal(parser.TextFrame, []byte("7"), &packet))
+	})
+}
+
+func TestOpen(t *testing.T) {
+	payload := &OpenPayload{
+		SID:          "lv_VI97HAXpY6yYWAAAC",
+		Upgrades:     []string{"websocket"},
+		PingInterval: 25000,
+		PingTimeout:  20000,
+		MaxPayload:   1000000,
+	}
+
+	packet, err := NewOpen(payload)
+	require.NoError(t, err)
+
+	_, frame, err := Marshal(packet)
+	require.NoError(t, err)
+	assert.Equal(t, `0{"sid":"lv_VI97HAXpY6yYWAAAC","upgrades":["websocket"],"pingInterval":25000,"pingTimeout":20000,"maxPayload":1000000}`, string(frame))
+
+	var decoded Packet
+	require.NoError(t, Unmarshal(parser.TextFrame, frame, &decoded))
+
+	var open OpenPayload
+	require.NoError(t, decoded.DecodeOpen(&open))
+	assert.Equal(t, payload, &open)
+
+	assert.Equal(t, ErrInvalidPacketType, (&Packet{Type: Ping}).DecodeOpen(&open))
+}
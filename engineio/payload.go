@@ -0,0 +1,156 @@
+package engineio
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+
+	parser "github.com/sshaplygin/go-socket.io-parser"
+)
+
+// recordSep separates packets in HTTP long-polling payload.
+const recordSep = byte('\x1e')
+
+// binaryPrefix marks base64 encoded binary packet in HTTP long-polling payload.
+const binaryPrefix = byte('b')
+
+// EncodePayload encodes packets into single HTTP long-polling payload of
+// Engine.IO v4: packets are separated by '\x1e' and binary ones are sent as
+// 'b' followed by base64 data.
+func EncodePayload(packets []*Packet) ([]byte, error) {
+	if len(packets) == 0 {
+		return nil, errors.New("empty packets source")
+	}
+
+	size := len(packets) - 1
+	for _, packet := range packets {
+		if packet == nil {
+			return nil, errors.New("empty packet source")
+		}
+
+		size += packetSize(packet)
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, size))
+	for idx, packet := range packets {
+		if idx > 0 {
+			_ = buf.WriteByte(recordSep)
+		}
+
+		if err := writePayloadPacket(buf, packet); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// EncodePayloads encodes packets into HTTP long-polling payloads, each of them
+// not exceeding maxPayload bytes announced by OpenPayload. Packet bigger than
+// maxPayload is sent in its own payload. Zero maxPayload means no limit.
+func EncodePayloads(packets []*Packet, maxPayload int) ([][]byte, error) {
+	if len(packets) == 0 {
+		return nil, errors.New("empty packets source")
+	}
+
+	var payloads [][]byte
+
+	start, size := 0, 0
+	for idx, packet := range packets {
+		if packet == nil {
+			return nil, errors.New("empty packet source")
+		}
+
+		next := packetSize(packet)
+		if idx > start {
+			next++ // separator
+		}
+
+		if maxPayload > 0 && idx > start && size+next > maxPayload {
+			payload, err := EncodePayload(packets[start:idx])
+			if err != nil {
+				return nil, err
+			}
+
+			payloads = append(payloads, payload)
+			start, size = idx, packetSize(packet)
+
+			continue
+		}
+
+		size += next
+	}
+
+	payload, err := EncodePayload(packets[start:])
+	if err != nil {
+		return nil, err
+	}
+
+	return append(payloads, payload), nil
+}
+
+// DecodePayload decodes packets of Engine.IO v4 HTTP long-polling payload.
+func DecodePayload(data []byte) ([]*Packet, error) {
+	if len(data) == 0 {
+		return nil, ErrEmptyPacket
+	}
+
+	records := bytes.Split(data, []byte{recordSep})
+	packets := make([]*Packet, 0, len(records))
+
+	for _, record := range records {
+		packet := &Packet{}
+
+		if len(record) > 0 && record[0] == binaryPrefix {
+			buf := make([]byte, base64.StdEncoding.DecodedLen(len(record)-1))
+
+			n, err := base64.StdEncoding.Decode(buf, record[1:])
+			if err != nil {
+				return nil, err
+			}
+
+			packet.Type = Message
+			packet.Data = buf[:n]
+			packet.IsBinary = true
+		} else if err := Unmarshal(parser.TextFrame, record, packet); err != nil {
+			return nil, err
+		}
+
+		packets = append(packets, packet)
+	}
+
+	return packets, nil
+}
+
+// packetSize returns length of packet encoded in payload.
+func packetSize(packet *Packet) int {
+	if packet.IsBinary {
+		return 1 + base64.StdEncoding.EncodedLen(len(packet.Data))
+	}
+
+	return 1 + len(packet.Data)
+}
+
+func writePayloadPacket(buf *bytes.Buffer, packet *Packet) error {
+	if !packet.IsBinary {
+		_, frame, err := Marshal(packet)
+		if err != nil {
+			return err
+		}
+
+		_, _ = buf.Write(frame)
+
+		return nil
+	}
+
+	if packet.Type != Message {
+		return ErrBinaryPacket
+	}
+
+	_ = buf.WriteByte(binaryPrefix)
+
+	enc := base64.NewEncoder(base64.StdEncoding, buf)
+	_, _ = enc.Write(packet.Data)
+
+	return enc.Close()
+}
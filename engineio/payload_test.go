@@ -0,0 +1,139 @@
+package engineio
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	parser "github.com/sshaplygin/go-socket.io-parser"
+)
+
+func TestEncodePayload(t *testing.T) {
+	packets := []*Packet{
+		{Type: Message, Data: []byte("€")},
+		{Type: Message, Data: []byte{1, 2, 3, 4}, IsBinary: true},
+		{Type: Ping},
+	}
+
+	payload, err := EncodePayload(packets)
+	require.NoError(t, err)
+	assert.Equal(t, "4€\x1ebAQIDBA==\x1e2", string(payload))
+
+	decoded, err := DecodePayload(payload)
+	require.NoError(t, err)
+	require.Len(t, decoded, len(packets))
+	for idx, packet := range packets {
+		assert.Equal(t, packet, decoded[idx])
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		_, err := EncodePayload(nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("binary ping", func(t *testing.T) {
+		_, err := EncodePayload([]*Packet{{Type: Ping, IsBinary: true}})
+		assert.Equal(t, ErrBinaryPacket, err)
+	})
+
+	t.Run("nil packet", func(t *testing.T) {
+		_, err := EncodePayload([]*Packet{{Type: Ping}, nil})
+		assert.Error(t, err)
+	})
+}
+
+func TestEncodePayloads(t *testing.T) {
+	packets := []*Packet{
+		{Type: Message, Data: []byte("aaaa")},                  // 5 bytes
+		{Type: Message, Data: []byte("bbbb")},                  // 5 bytes
+		{Type: Message, Data: []byte{1, 2, 3}, IsBinary: true}, // 5 bytes
+		{Type: Message, Data: []byte("cccccccccccccccc")},      // 17 bytes
+		{Type: Ping}, // 1 byte
+	}
+
+	payloads, err := EncodePayloads(packets, 11)
+	require.NoError(t, err)
+
+	var got []string
+	for _, payload := range payloads {
+		assert.True(t, len(payload) <= 11 || len(payload) == 17)
+		got = append(got, string(payload))
+	}
+
+	assert.Equal(t, []string{
+		"4aaaa\x1e4bbbb",
+		"bAQID",
+		"4cccccccccccccccc",
+		"2",
+	}, got)
+
+	t.Run("no limit", func(t *testing.T) {
+		payloads, err := EncodePayloads(packets, 0)
+		require.NoError(t, err)
+		require.Len(t, payloads, 1)
+
+		payload, err := EncodePayload(packets)
+		require.NoError(t, err)
+		assert.Equal(t, payload, payloads[0])
+	})
+
+	t.Run("nil packet", func(t *testing.T) {
+		_, err := EncodePayloads([]*Packet{{Type: Ping}, nil}, 11)
+		assert.Error(t, err)
+	})
+}
+
+func TestDecodePayload(t *testing.T) {
+	packets, err := DecodePayload([]byte("0{\"sid\":\"abc\"}\x1e40\x1e6"))
+	require.NoError(t, err)
+
+	assert.Equal(t, []*Packet{
+		{Type: Open, Data: []byte(`{"sid":"abc"}`)},
+		{Type: Message, Data: []byte("0")},
+		{Type: Noop},
+	}, packets)
+
+	t.Run("empty", func(t *testing.T) {
+		_, err := DecodePayload(nil)
+		assert.Equal(t, ErrEmptyPacket, err)
+	})
+
+	t.Run("empty record", func(t *testing.T) {
+		_, err := DecodePayload([]byte("4a\x1e"))
+		assert.Equal(t, ErrEmptyPacket, err)
+	})
+
+	t.Run("invalid base64", func(t *testing.T) {
+		_, err := DecodePayload([]byte("b!!"))
+		assert.Error(t, err)
+	})
+}
+
+func TestPayload_socketio(t *testing.T) {
+	packets, err := MarshalMessage(&parser.Packet{
+		Header: parser.Header{Type: parser.Event},
+		Data:   []interface{}{"upload", &parser.Buffer{Data: []byte{0xff, 0x00}}},
+	})
+	require.NoError(t, err)
+
+	payload, err := EncodePayload(packets)
+	require.NoError(t, err)
+	assert.Equal(t, `451-["upload",{"_placeholder":true,"num":0}]`+"\x1eb"+base64.StdEncoding.EncodeToString([]byte{0xff, 0x00}), string(payload))
+
+	decoded, err := DecodePayload(payload)
+	require.NoError(t, err)
+	require.Len(t, decoded, 2)
+
+	_, frame, err := Marshal(decoded[0])
+	require.NoError(t, err)
+
+	var message parser.Packet
+	require.NoError(t, UnmarshalMessage(frame, [][]byte{decoded[1].Data}, &message))
+
+	assert.Equal(t, []interface{}{
+		"upload",
+		&parser.Buffer{IsBinary: true, Data: []byte{0xff, 0x00}},
+	}, message.Data)
+}
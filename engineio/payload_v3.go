@@ -0,0 +1,270 @@
+package engineio
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"unicode/utf8"
+
+	parser "github.com/sshaplygin/go-socket.io-parser"
+)
+
+// Engine.IO v3 (socket.io 2.x) HTTP long-polling payloads.
+const (
+	// lengthSep ends packet length in v3 text payload.
+	lengthSep = byte(':')
+	// stringMarker and binaryMarker start packet in v3 binary payload.
+	stringMarker = byte(0)
+	binaryMarker = byte(1)
+	// lengthEnd ends packet length digits in v3 binary payload.
+	lengthEnd = byte(0xff)
+)
+
+// EncodePayloadV3 encodes packets into Engine.IO v3 text payload: every packet
+// is prefixed by '<length>:', where length is counted in UTF-16 code units as
+// JavaScript string.length. Binary packets are sent as 'b<type><base64>'.
+func EncodePayloadV3(packets []*Packet) ([]byte, error) {
+	if len(packets) == 0 {
+		return nil, errors.New("empty packets source")
+	}
+
+	var buf bytes.Buffer
+	for _, packet := range packets {
+		encoded, err := encodePacketV3(packet)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.WriteString(strconv.Itoa(utf16Len(encoded)))
+		_ = buf.WriteByte(lengthSep)
+		_, _ = buf.Write(encoded)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// DecodePayloadV3 decodes packets of Engine.IO v3 text payload.
+func DecodePayloadV3(data []byte) ([]*Packet, error) {
+	if len(data) == 0 {
+		return nil, ErrEmptyPacket
+	}
+
+	var packets []*Packet
+	for len(data) > 0 {
+		sep := bytes.IndexByte(data, lengthSep)
+		if sep <= 0 {
+			return nil, ErrInvalidPayload
+		}
+
+		length, err := strconv.Atoi(string(data[:sep]))
+		if err != nil || length < 0 {
+			return nil, ErrInvalidPayload
+		}
+		data = data[sep+1:]
+
+		n, ok := utf16Prefix(data, length)
+		if !ok {
+			return nil, ErrInvalidPayload
+		}
+
+		packet, err := decodePacketV3(data[:n])
+		if err != nil {
+			return nil, err
+		}
+
+		packets = append(packets, packet)
+		data = data[n:]
+	}
+
+	return packets, nil
+}
+
+// EncodeBinaryPayloadV3 encodes packets into Engine.IO v3 binary payload:
+// every packet is prefixed by 0 for string or 1 for binary packet, its length
+// in bytes as one byte per decimal digit and 0xff.
+func EncodeBinaryPayloadV3(packets []*Packet) ([]byte, error) {
+	if len(packets) == 0 {
+		return nil, errors.New("empty packets source")
+	}
+
+	var buf bytes.Buffer
+	for _, packet := range packets {
+		if packet == nil {
+			return nil, errors.New("empty packet source")
+		}
+		if !packet.Type.IsValid() {
+			return nil, ErrInvalidPacketType
+		}
+
+		marker := stringMarker
+		if packet.IsBinary {
+			if packet.Type != Message {
+				return nil, ErrBinaryPacket
+			}
+
+			marker = binaryMarker
+		}
+
+		_ = buf.WriteByte(marker)
+		for _, digit := range strconv.Itoa(len(packet.Data) + 1) {
+			_ = buf.WriteByte(byte(digit) - zeroNumberByte)
+		}
+		_ = buf.WriteByte(lengthEnd)
+
+		if packet.IsBinary {
+			_ = buf.WriteByte(byte(packet.Type))
+		} else {
+			_ = buf.WriteByte(byte(packet.Type) + zeroNumberByte)
+		}
+		_, _ = buf.Write(packet.Data)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// DecodeBinaryPayloadV3 decodes packets of Engine.IO v3 binary payload.
+func DecodeBinaryPayloadV3(data []byte) ([]*Packet, error) {
+	if len(data) == 0 {
+		return nil, ErrEmptyPacket
+	}
+
+	var packets []*Packet
+	for len(data) > 0 {
+		marker := data[0]
+		if marker != stringMarker && marker != binaryMarker {
+			return nil, ErrInvalidPayload
+		}
+
+		end := bytes.IndexByte(data, lengthEnd)
+		if end < 2 {
+			return nil, ErrInvalidPayload
+		}
+
+		var length int
+		for _, digit := range data[1:end] {
+			if digit > 9 || length > (len(data)-int(digit))/10 {
+				return nil, ErrInvalidPayload
+			}
+
+			length = length*10 + int(digit)
+		}
+
+		data = data[end+1:]
+		if length == 0 || length > len(data) {
+			return nil, ErrInvalidPayload
+		}
+
+		packet := &Packet{}
+		if marker == binaryMarker {
+			packet.Type = Type(data[0])
+			packet.Data = append([]byte(nil), data[1:length]...)
+			packet.IsBinary = true
+
+			if packet.Type != Message {
+				return nil, ErrBinaryPacket
+			}
+		} else if err := Unmarshal(parser.TextFrame, data[:length], packet); err != nil {
+			return nil, err
+		}
+
+		packets = append(packets, packet)
+		data = data[length:]
+	}
+
+	return packets, nil
+}
+
+func encodePacketV3(packet *Packet) ([]byte, error) {
+	if packet == nil {
+		return nil, errors.New("empty packet source")
+	}
+
+	if !packet.IsBinary {
+		_, frame, err := Marshal(packet)
+
+		return frame, err
+	}
+
+	if packet.Type != Message {
+		return nil, ErrBinaryPacket
+	}
+
+	encoded := make([]byte, 2+base64.StdEncoding.EncodedLen(len(packet.Data)))
+	encoded[0] = binaryPrefix
+	encoded[1] = byte(packet.Type) + zeroNumberByte
+	base64.StdEncoding.Encode(encoded[2:], packet.Data)
+
+	return encoded, nil
+}
+
+func decodePacketV3(data []byte) (*Packet, error) {
+	packet := &Packet{}
+
+	if len(data) == 0 || data[0] != binaryPrefix {
+		if err := Unmarshal(parser.TextFrame, data, packet); err != nil {
+			return nil, err
+		}
+
+		return packet, nil
+	}
+
+	if len(data) < 2 {
+		return nil, ErrEmptyPacket
+	}
+
+	packet.Type = Type(data[1] - zeroNumberByte)
+	if packet.Type != Message {
+		return nil, ErrBinaryPacket
+	}
+
+	buf := make([]byte, base64.StdEncoding.DecodedLen(len(data)-2))
+	n, err := base64.StdEncoding.Decode(buf, data[2:])
+	if err != nil {
+		return nil, err
+	}
+
+	packet.Data = buf[:n]
+	packet.IsBinary = true
+
+	return packet, nil
+}
+
+// utf16Len returns length of UTF-8 text in UTF-16 code units.
+func utf16Len(data []byte) int {
+	var n int
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r > 0xffff {
+			n += 2
+		} else {
+			n++
+		}
+
+		data = data[size:]
+	}
+
+	return n
+}
+
+// utf16Prefix returns count of bytes of UTF-8 text prefix which is units long
+// in UTF-16 code units.
+func utf16Prefix(data []byte, units int) (int, bool) {
+	var n int
+	for units > 0 {
+		if n >= len(data) {
+			return 0, false
+		}
+
+		r, size := utf8.DecodeRune(data[n:])
+		if r > 0xffff {
+			units -= 2
+		} else {
+			units--
+		}
+
+		n += size
+	}
+
+	return n, units == 0
+}
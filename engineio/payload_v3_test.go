@@ -0,0 +1,86 @@
+package engineio
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type payloadV3TestCase struct {
+	Name    string
+	Packets []*Packet
+	Text    string
+	Binary  []byte
+}
+
+var payloadV3Tests = []payloadV3TestCase{
+	{
+		Name:    "message",
+		Packets: []*Packet{{Type: Message, Data: []byte("hello")}},
+		Text:    "6:4hello",
+		Binary:  []byte{0, 6, 0xff, '4', 'h', 'e', 'l', 'l', 'o'},
+	},
+	{
+		Name:    "multibyte",
+		Packets: []*Packet{{Type: Message, Data: []byte("€")}},
+		Text:    "2:4€",
+		Binary:  []byte{0, 4, 0xff, '4', 0xe2, 0x82, 0xac},
+	},
+	{
+		Name:    "surrogate pair",
+		Packets: []*Packet{{Type: Message, Data: []byte("😀")}, {Type: Ping}},
+		Text:    "3:4😀1:2",
+		Binary:  []byte{0, 5, 0xff, '4', 0xf0, 0x9f, 0x98, 0x80, 0, 1, 0xff, '2'},
+	},
+	{
+		Name:    "binary",
+		Packets: []*Packet{{Type: Message, Data: []byte{1, 2, 3}, IsBinary: true}},
+		Text:    "6:b4AQID",
+		Binary:  []byte{1, 4, 0xff, 4, 1, 2, 3},
+	},
+	{
+		Name: "mixed",
+		Packets: []*Packet{
+			{Type: Message, Data: []byte(`51-["msg",{"_placeholder":true,"num":0}]`)},
+			{Type: Message, Data: []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, IsBinary: true},
+		},
+		Text: `41:451-["msg",{"_placeholder":true,"num":0}]18:b4AQIDBAUGBwgJCg==`,
+		Binary: append(append([]byte{0, 4, 1, 0xff}, `451-["msg",{"_placeholder":true,"num":0}]`...),
+			1, 1, 1, 0xff, 4, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10),
+	},
+}
+
+func TestPayloadV3(t *testing.T) {
+	for _, test := range payloadV3Tests {
+		t.Run(test.Name, func(t *testing.T) {
+			text, err := EncodePayloadV3(test.Packets)
+			require.NoError(t, err)
+			assert.Equal(t, test.Text, string(text))
+
+			packets, err := DecodePayloadV3(text)
+			require.NoError(t, err)
+			assert.Equal(t, test.Packets, packets)
+
+			binary, err := EncodeBinaryPayloadV3(test.Packets)
+			require.NoError(t, err)
+			assert.Equal(t, test.Binary, binary)
+
+			packets, err = DecodeBinaryPayloadV3(binary)
+			require.NoError(t, err)
+			assert.Equal(t, test.Packets, packets)
+		})
+	}
+}
+
+func TestDecodePayloadV3_invalid(t *testing.T) {
+	for _, data := range []string{"", "4hello", "x:4", "9:4hello", "-1:4", "2:4😀"} {
+		_, err := DecodePayloadV3([]byte(data))
+		assert.Error(t, err, data)
+	}
+
+	for _, data := range [][]byte{nil, {2, 1, 0xff, '4'}, {0, 0xff, '4'}, {0, 9, 0xff, '4'}, {0, 12, 0xff, '4'}, {1, 2, 0xff, 2, 1}} {
+		_, err := DecodeBinaryPayloadV3(data)
+		assert.Error(t, err, "%v", data)
+	}
+}
@@ -0,0 +1,52 @@
+package engineio
+
+//go:generate stringer -type=Type
+
+// Type of Engine.IO packet.
+type Type byte
+
+// Engine.IO protocol packet types.
+const (
+	// Open is sent by server when new transport is opened, it contains handshake data.
+	Open Type = iota
+	// Close requests the close of transport but does not shutdown the connection itself.
+	Close
+	// Ping is sent by server, client should answer with Pong packet.
+	// During upgrade client sends probe ping with "probe" data.
+	Ping
+	// Pong is sent by client in response to Ping packet.
+	Pong
+	// Message is actual message, it carries socket.io packets.
+	Message
+	// Upgrade is sent by client before switching transport.
+	Upgrade
+	// Noop is used to force polling cycle during upgrade.
+	Noop
+)
+
+func (i Type) IsValid() bool {
+	return i <= Noop
+}
+
+// Packet of Engine.IO protocol.
+type Packet struct {
+	Type Type
+	// Data of packet: UTF-8 text or binary message data.
+	Data []byte
+	// IsBinary marks Message packet with binary data.
+	IsBinary bool
+}
+
+// OpenPayload is the handshake data of Open packet.
+type OpenPayload struct {
+	// SID is the session id.
+	SID string `json:"sid"`
+	// Upgrades is the list of available transport upgrades.
+	Upgrades []string `json:"upgrades"`
+	// PingInterval in milliseconds.
+	PingInterval int `json:"pingInterval"`
+	// PingTimeout in milliseconds.
+	PingTimeout int `json:"pingTimeout"`
+	// MaxPayload is the max number of bytes per chunk, used by polling transport.
+	MaxPayload int `json:"maxPayload"`
+}
@@ -1,6 +1,9 @@
 package go_socketio_parser
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	// ErrInvalidPackageType type
@@ -10,6 +13,83 @@ var (
 	//ErrShouldTextPackageType
 	ErrShouldTextPackageType = errors.New("first packet should be TEXT frame")
 
+	// ErrInvalidPacket is wrapped by errors of Packet.Validate.
+	ErrInvalidPacket = errors.New("invalid packet")
+
+	// ErrIllegalAttachments is returned for malformed count of binary attachments
+	// or when it does not match count of placeholders.
+	ErrIllegalAttachments = errors.New("illegal attachments")
+	// ErrNumberOverflow is returned for attachments count or acknowledgment id out of uint64.
+	ErrNumberOverflow = errors.New("number overflow")
+	// ErrInvalidPayload is returned for payload of unexpected shape or trailing data.
+	ErrInvalidPayload = errors.New("invalid data segment")
+	// ErrInvalidPlaceholder is returned for binary placeholder with num which is
+	// not an integer, negative, duplicated or out of attachments count.
+	ErrInvalidPlaceholder = errors.New("invalid placeholder")
+	// ErrMissingAttachments is returned when binary attachments are not found.
+	ErrMissingAttachments = errors.New("not found binary attachments")
+
 	// ErrBufferAddress
+	//
+	// Deprecated: buffers are encoded by value, it is not returned anymore.
 	ErrBufferAddress = errors.New("invalid buffer address")
+	// ErrBufferNum
+	ErrBufferNum = errors.New("invalid buffer number")
+	// ErrAttachmentSeparator is returned by Marshal for attachment containing
+	// '\n' separator which is followed by another attachment.
+	ErrAttachmentSeparator = errors.New("attachment contains separator")
+	// ErrFrameSize is returned for frame which length doesn't fit frame header.
+	ErrFrameSize = errors.New("invalid frame size")
+	// ErrInvalidFrame is returned for frame header with unknown frame type.
+	ErrInvalidFrame = errors.New("invalid frame type")
+)
+
+// Section of encoded packet.
+type Section byte
+
+// encoded packet sections in order of appearance.
+const (
+	SectionType Section = iota
+	SectionAttachments
+	SectionNamespace
+	SectionID
+	SectionPayload
+	SectionAttachment
 )
+
+var sectionNames = [...]string{
+	SectionType:        "packet type",
+	SectionAttachments: "attachments count",
+	SectionNamespace:   "namespace",
+	SectionID:          "acknowledgment id",
+	SectionPayload:     "payload",
+	SectionAttachment:  "attachment",
+}
+
+func (s Section) String() string {
+	if int(s) < len(sectionNames) {
+		return sectionNames[s]
+	}
+
+	return fmt.Sprintf("Section(%d)", s)
+}
+
+// DecodeError describes where decoding of packet failed.
+type DecodeError struct {
+	// Offset of the failed section start in the text frame, or in the whole
+	// input for Unmarshal. JSON syntax errors point to the exact byte.
+	Offset int64
+	// Section of packet which failed.
+	Section Section
+	// Err is the cause: one of the exported sentinels, *json.SyntaxError or
+	// io.ErrUnexpectedEOF.
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("socket.io decode %s at offset %d: %v", e.Section, e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,149 @@
+package go_socketio_parser
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type decodeErrorTestCase struct {
+	name    string
+	data    string
+	offset  int64
+	section Section
+	err     error
+}
+
+var decodeErrorTests = []decodeErrorTestCase{
+	{
+		name:    "empty",
+		data:    "",
+		section: SectionType,
+		err:     io.ErrUnexpectedEOF,
+	},
+	{
+		name:    "invalid type",
+		data:    "9",
+		section: SectionType,
+		err:     ErrInvalidPackageType,
+	},
+	{
+		name:    "attachments without separator",
+		data:    `51["msg"]`,
+		offset:  1,
+		section: SectionAttachments,
+		err:     ErrIllegalAttachments,
+	},
+	{
+		name:    "attachments without count",
+		data:    `5-["msg"]`,
+		offset:  1,
+		section: SectionAttachments,
+		err:     ErrIllegalAttachments,
+	},
+	{
+		name:    "attachments overflow",
+		data:    `518446744073709551616-["msg"]`,
+		offset:  1,
+		section: SectionAttachments,
+		err:     ErrNumberOverflow,
+	},
+	{
+		name:    "id overflow",
+		data:    `2/woot,18446744073709551616["msg"]`,
+		offset:  7,
+		section: SectionID,
+		err:     ErrNumberOverflow,
+	},
+	{
+		name:    "payload not array",
+		data:    `2/woot,1"msg"`,
+		offset:  8,
+		section: SectionPayload,
+		err:     ErrInvalidPayload,
+	},
+	{
+		name:    "payload trailing data",
+		data:    `2["msg"]]`,
+		offset:  8,
+		section: SectionPayload,
+		err:     ErrInvalidPayload,
+	},
+	{
+		name:    "payload unterminated",
+		data:    `2["msg",1`,
+		offset:  9,
+		section: SectionPayload,
+		err:     io.ErrUnexpectedEOF,
+	},
+	{
+		name:    "missing attachments",
+		data:    `51-["msg",{"_placeholder":true,"num":0}]`,
+		offset:  40,
+		section: SectionAttachment,
+		err:     ErrMissingAttachments,
+	},
+	{
+		name:    "placeholder number",
+		data:    `51-["msg",{"_placeholder":true,"num":3}]` + "\n\x01",
+		offset:  40,
+		section: SectionPayload,
+		err:     ErrInvalidPlaceholder,
+	},
+	{
+		name:    "placeholders count",
+		data:    `52-["msg",{"_placeholder":true,"num":0}]` + "\n\x01",
+		offset:  40,
+		section: SectionPayload,
+		err:     ErrIllegalAttachments,
+	},
+}
+
+func TestDecodeError(t *testing.T) {
+	for _, test := range decodeErrorTests {
+		t.Run(test.name, func(t *testing.T) {
+			var message Packet
+			err := Unmarshal([]byte(test.data), &message)
+			require.Error(t, err)
+
+			var decodeErr *DecodeError
+			require.True(t, errors.As(err, &decodeErr), err.Error())
+
+			assert.Equal(t, test.offset, decodeErr.Offset)
+			assert.Equal(t, test.section, decodeErr.Section)
+			assert.True(t, errors.Is(err, test.err), err.Error())
+		})
+	}
+
+	t.Run("json syntax", func(t *testing.T) {
+		var message Packet
+		err := Unmarshal([]byte(`2/woot,["msg",}]`), &message)
+
+		var decodeErr *DecodeError
+		require.True(t, errors.As(err, &decodeErr))
+		assert.Equal(t, int64(14), decodeErr.Offset)
+		assert.Equal(t, SectionPayload, decodeErr.Section)
+
+		var syntaxErr *json.SyntaxError
+		assert.True(t, errors.As(err, &syntaxErr))
+	})
+
+	t.Run("frames", func(t *testing.T) {
+		var message Packet
+		err := UnmarshalFrames(`51-["msg",{"_placeholder":true,"num":0}]`, nil, &message)
+
+		var decodeErr *DecodeError
+		require.True(t, errors.As(err, &decodeErr))
+		assert.Equal(t, SectionAttachment, decodeErr.Section)
+		assert.True(t, errors.Is(err, ErrMissingAttachments))
+	})
+
+	t.Run("message", func(t *testing.T) {
+		err := &DecodeError{Offset: 3, Section: SectionNamespace, Err: ErrInvalidPayload}
+		assert.Equal(t, "socket.io decode namespace at offset 3: invalid data segment", err.Error())
+	})
+}
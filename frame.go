@@ -0,0 +1,99 @@
+package go_socketio_parser
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Byte streams (e.g. TCP connections, files or bytes.Buffer) don't keep frame
+// boundaries, so every frame is prefixed by its header: FrameType byte and
+// frame length as big-endian uint32.
+const frameHeaderSize = 5
+
+type streamFrameWriter struct {
+	w   io.Writer
+	buf []byte
+}
+
+// NewFrameWriter returns FrameWriter writing frames with their headers to byte
+// stream w. Every frame is written by a single call.
+func NewFrameWriter(w io.Writer) FrameWriter {
+	return &streamFrameWriter{
+		w: w,
+	}
+}
+
+func (fw *streamFrameWriter) WriteFrame(ft FrameType, frame []byte) error {
+	if uint64(len(frame)) > uint64(^uint32(0)) {
+		return ErrFrameSize
+	}
+
+	fw.buf = append(fw.buf[:0], byte(ft), 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(fw.buf[1:frameHeaderSize], uint32(len(frame)))
+	fw.buf = append(fw.buf, frame...)
+
+	n, err := fw.w.Write(fw.buf)
+	if err != nil {
+		return err
+	}
+	if n != len(fw.buf) {
+		return io.ErrShortWrite
+	}
+
+	return nil
+}
+
+// maxFrameChunk bounds memory allocated ahead of received frame data, so
+// declared frame length alone can't exhaust memory.
+const maxFrameChunk = 64 << 10
+
+type streamFrameReader struct {
+	r      io.Reader
+	limits Limits
+	header [frameHeaderSize]byte
+	buf    []byte
+}
+
+// NewFrameReader returns FrameReader reading frames written by NewFrameWriter
+// from byte stream r. The returned frame is only valid until the next call.
+func NewFrameReader(r io.Reader) FrameReader {
+	return &streamFrameReader{
+		r: r,
+	}
+}
+
+func (fr *streamFrameReader) NextFrame() (FrameType, []byte, error) {
+	if _, err := io.ReadFull(fr.r, fr.header[:]); err != nil {
+		return 0, nil, err
+	}
+
+	ft := FrameType(fr.header[0])
+	if ft != TextFrame && ft != BinaryFrame {
+		return 0, nil, ErrInvalidFrame
+	}
+
+	size := uint64(binary.BigEndian.Uint32(fr.header[1:]))
+	if err := fr.limits.checkPacketSize(int(size)); err != nil {
+		return 0, nil, err
+	}
+
+	fr.buf = fr.buf[:0]
+	for uint64(len(fr.buf)) < size {
+		n := size - uint64(len(fr.buf))
+		if n > maxFrameChunk {
+			n = maxFrameChunk
+		}
+
+		start := len(fr.buf)
+		fr.buf = append(fr.buf, make([]byte, n)...)
+		if _, err := io.ReadFull(fr.r, fr.buf[start:]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+
+			return 0, nil, err
+		}
+	}
+
+	return ft, fr.buf, nil
+}
@@ -0,0 +1,70 @@
+package go_socketio_parser
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type shortWriter struct{}
+
+func (shortWriter) Write(p []byte) (int, error) {
+	return len(p) - 1, nil
+}
+
+func TestFrameWriter(t *testing.T) {
+	var buf bytes.Buffer
+	fw := NewFrameWriter(&buf)
+
+	require.NoError(t, fw.WriteFrame(TextFrame, []byte(`2["a"]`)))
+	require.NoError(t, fw.WriteFrame(BinaryFrame, []byte{1, 2, 3}))
+	require.NoError(t, fw.WriteFrame(BinaryFrame, nil))
+
+	assert.Equal(t, []byte{
+		0, 0, 0, 0, 6, '2', '[', '"', 'a', '"', ']',
+		1, 0, 0, 0, 3, 1, 2, 3,
+		1, 0, 0, 0, 0,
+	}, buf.Bytes())
+
+	t.Run("write error", func(t *testing.T) {
+		writeErr := errors.New("closed")
+
+		err := NewFrameWriter(errWriter{err: writeErr}).WriteFrame(TextFrame, []byte(`1`))
+		assert.Equal(t, writeErr, err)
+	})
+
+	t.Run("short write", func(t *testing.T) {
+		err := NewFrameWriter(shortWriter{}).WriteFrame(TextFrame, []byte(`1`))
+		assert.Equal(t, io.ErrShortWrite, err)
+	})
+}
+
+func TestFrameReader(t *testing.T) {
+	fr := NewFrameReader(bytes.NewReader([]byte{
+		0, 0, 0, 0, 1, '1',
+		1, 0, 0, 0, 2, 1, 2,
+		1, 0, 0, 0, 0,
+	}))
+
+	for _, expected := range []frame{
+		{Type: TextFrame, Data: []byte(`1`)},
+		{Type: BinaryFrame, Data: []byte{1, 2}},
+		{Type: BinaryFrame, Data: []byte{}},
+	} {
+		ft, data, err := fr.NextFrame()
+		require.NoError(t, err)
+		assert.Equal(t, expected, frame{Type: ft, Data: data})
+	}
+
+	_, _, err := fr.NextFrame()
+	assert.Equal(t, io.EOF, err)
+
+	t.Run("truncated header", func(t *testing.T) {
+		_, _, err := NewFrameReader(bytes.NewReader([]byte{0, 0})).NextFrame()
+		assert.Equal(t, io.ErrUnexpectedEOF, err)
+	})
+}
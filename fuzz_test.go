@@ -0,0 +1,176 @@
+//go:build go1.18
+
+package go_socketio_parser
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Crashers found by fuzzing are kept in testdata/fuzz as regression corpus.
+
+func FuzzUnmarshal(f *testing.F) {
+	for _, test := range tests {
+		f.Add([]byte(test.Tmpl))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var raw Packet
+		rawErr := Unmarshal(append([]byte(nil), data...), &raw, WithZeroCopy())
+
+		var message Packet
+		err := Unmarshal(data, &message)
+		assert.Equal(t, err == nil, rawErr == nil, "zero copy decoding: %v", rawErr)
+		if err != nil {
+			return
+		}
+
+		encoded, err := Marshal(&message)
+		require.NoError(t, err)
+
+		var decoded Packet
+		require.NoError(t, Unmarshal(encoded, &decoded), "%q", encoded)
+		assertEquivalent(t, &message, &decoded)
+	})
+}
+
+func FuzzUnmarshalFrames(f *testing.F) {
+	for _, test := range tests {
+		frames := strings.Split(test.Tmpl, string('\n'))
+
+		f.Add(frames[0], joinAttachments(stringsToBytes(frames[1:])))
+	}
+
+	f.Fuzz(func(t *testing.T, text string, data []byte) {
+		var message Packet
+		if err := UnmarshalFrames(text, splitAttachments(data), &message); err != nil {
+			return
+		}
+
+		encoded, attachments, err := MarshalFrames(&message)
+		require.NoError(t, err)
+
+		var decoded Packet
+		require.NoError(t, UnmarshalFrames(encoded, attachments, &decoded), "%q", encoded)
+		assertEquivalent(t, &message, &decoded)
+	})
+}
+
+// FuzzMarshal encodes event with fuzzed attachments and checks that both
+// single-buffer and frames encodings are decoded back to the same packet.
+func FuzzMarshal(f *testing.F) {
+	f.Add("msg", joinAttachments([][]byte{{1, 2, 3}}))
+	f.Add("msg", joinAttachments([][]byte{{1}, {}, {2, 3}}))
+	f.Add("upload", joinAttachments([][]byte{{'\n'}, {'a', '\n', 'b'}}))
+
+	f.Fuzz(func(t *testing.T, event string, data []byte) {
+		// encoding/json replaces invalid UTF-8 of the event name.
+		if !utf8.ValidString(event) {
+			return
+		}
+
+		attachments := splitAttachments(data)
+
+		packet := &Packet{
+			Header: Header{Type: Event, Namespace: "/fuzz", ID: 1, HasID: true},
+			Data:   []interface{}{event},
+		}
+		for i, attachment := range attachments {
+			packet.Data = append(packet.Data, &Buffer{IsBinary: true, Num: uint64(i), Data: attachment})
+		}
+
+		text, frames, err := MarshalFrames(packet)
+		require.NoError(t, err)
+		assert.Equal(t, attachments, frames)
+
+		var decoded Packet
+		require.NoError(t, UnmarshalFrames(text, frames, &decoded), "%q", text)
+		assert.Equal(t, packet, &decoded)
+
+		encoded, err := Marshal(packet)
+
+		separator := false
+		for i := 0; i < len(attachments)-1; i++ {
+			separator = separator || bytes.IndexByte(attachments[i], '\n') >= 0
+		}
+		if separator {
+			assert.True(t, errors.Is(err, ErrAttachmentSeparator), "expected separator error, got %v", err)
+			return
+		}
+		require.NoError(t, err)
+
+		decoded = Packet{}
+		require.NoError(t, Unmarshal(encoded, &decoded), "%q", encoded)
+		assert.Equal(t, packet, &decoded)
+	})
+}
+
+// splitAttachments splits fuzzed data to attachments, every attachment is
+// prefixed by its length byte. The rest of data is the last attachment.
+func splitAttachments(data []byte) [][]byte {
+	var attachments [][]byte
+	for len(data) > 0 {
+		n := int(data[0])
+		data = data[1:]
+		if n > len(data) {
+			n = len(data)
+		}
+
+		attachments = append(attachments, append([]byte{}, data[:n]...))
+		data = data[n:]
+	}
+
+	return attachments
+}
+
+// joinAttachments is the reverse of splitAttachments for seeds.
+func joinAttachments(attachments [][]byte) []byte {
+	data := []byte{}
+	for _, attachment := range attachments {
+		data = append(data, byte(len(attachment)))
+		data = append(data, attachment...)
+	}
+
+	return data
+}
+
+func stringsToBytes(values []string) [][]byte {
+	ret := make([][]byte, 0, len(values))
+	for _, v := range values {
+		ret = append(ret, []byte(v))
+	}
+
+	return ret
+}
+
+// assertEquivalent compares packets ignoring numbers of binary placeholders,
+// which are assigned by encoder in its own order.
+func assertEquivalent(t *testing.T, expected, actual *Packet) {
+	t.Helper()
+
+	resetBufferNum(expected.Data)
+	resetBufferNum(actual.Data)
+
+	assert.Equal(t, expected, actual)
+}
+
+func resetBufferNum(v interface{}) {
+	switch val := v.(type) {
+	case *Buffer:
+		val.Num = 0
+	case []interface{}:
+		for _, item := range val {
+			resetBufferNum(item)
+		}
+	case map[string]interface{}:
+		for _, item := range val {
+			resetBufferNum(item)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package go_socketio_parser
+
+import (
+	"fmt"
+	"io"
+)
+
+// Limits of decoded packets protecting from hostile input. Zero value of
+// a field means no limit.
+type Limits struct {
+	// MaxPacketSize is the max size of text frame and all attachments in bytes.
+	MaxPacketSize int
+	// MaxAttachments is the max count of binary attachments.
+	MaxAttachments int
+	// MaxAttachmentSize is the max size of single binary attachment in bytes.
+	MaxAttachmentSize int
+	// MaxDepth is the max nesting of JSON arrays and objects, arguments array
+	// itself is the first level.
+	MaxDepth int
+	// MaxNamespaceLength is the max length of namespace in bytes.
+	MaxNamespaceLength int
+	// MaxArgs is the max count of packet arguments.
+	MaxArgs int
+}
+
+// WithLimits enforces limits on decoded packets.
+func WithLimits(limits Limits) Option {
+	return func(o *options) {
+		o.limits = limits
+	}
+}
+
+// LimitError is returned when decoded packet exceeds one of Limits.
+type LimitError struct {
+	// Limit is the name of exceeded Limits field.
+	Limit string
+	// Max is the value of exceeded limit.
+	Max int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("socket.io packet exceeds %s of %d", e.Limit, e.Max)
+}
+
+func exceeds(max, value int) bool {
+	return max > 0 && value > max
+}
+
+func (l Limits) checkPacketSize(size int) error {
+	if exceeds(l.MaxPacketSize, size) {
+		return &LimitError{Limit: "MaxPacketSize", Max: l.MaxPacketSize}
+	}
+
+	return nil
+}
+
+func (l Limits) checkAttachments(count uint64) error {
+	if l.MaxAttachments > 0 && count > uint64(l.MaxAttachments) {
+		return &LimitError{Limit: "MaxAttachments", Max: l.MaxAttachments}
+	}
+
+	return nil
+}
+
+func (l Limits) checkAttachmentSize(size int) error {
+	if exceeds(l.MaxAttachmentSize, size) {
+		return &LimitError{Limit: "MaxAttachmentSize", Max: l.MaxAttachmentSize}
+	}
+
+	return nil
+}
+
+func (l Limits) checkNamespace(length int) error {
+	if exceeds(l.MaxNamespaceLength, length) {
+		return &LimitError{Limit: "MaxNamespaceLength", Max: l.MaxNamespaceLength}
+	}
+
+	return nil
+}
+
+// checkJSON scans JSON value read from r without decoding it and checks its
+// nesting depth and count of the top-level elements. It returns position of
+// the byte exceeding a limit.
+func (l Limits) checkJSON(r io.ByteReader) (int, error) {
+	if l.MaxDepth == 0 && l.MaxArgs == 0 {
+		return 0, nil
+	}
+
+	var depth, commas int
+	var inString, escaped bool
+
+	for i := 0; ; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil
+		}
+
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == '"':
+				inString = false
+			}
+
+			continue
+		}
+
+		switch b {
+		case '"':
+			inString = true
+		case '[', '{':
+			depth++
+			if exceeds(l.MaxDepth, depth) {
+				return i, &LimitError{Limit: "MaxDepth", Max: l.MaxDepth}
+			}
+		case ']', '}':
+			depth--
+			if depth <= 0 {
+				return 0, nil
+			}
+		case ',':
+			// n elements are separated by n-1 commas.
+			if depth == 1 {
+				commas++
+				if l.MaxArgs > 0 && commas >= l.MaxArgs {
+					return i, &LimitError{Limit: "MaxArgs", Max: l.MaxArgs}
+				}
+			}
+		}
+	}
+}
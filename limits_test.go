@@ -0,0 +1,136 @@
+package go_socketio_parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type limitsTestCase struct {
+	name   string
+	limits Limits
+	data   string
+	limit  string // exceeded limit, empty if packet fits
+}
+
+var limitsTests = []limitsTestCase{
+	{
+		name:   "packet size",
+		limits: Limits{MaxPacketSize: 8},
+		data:   `2["msg",1]`,
+		limit:  "MaxPacketSize",
+	},
+	{
+		name:   "packet size fits",
+		limits: Limits{MaxPacketSize: 10},
+		data:   `2["msg",1]`,
+	},
+	{
+		name:   "attachments count",
+		limits: Limits{MaxAttachments: 2},
+		data:   `5999999-["msg"]`,
+		limit:  "MaxAttachments",
+	},
+	{
+		name:   "placeholders count",
+		limits: Limits{MaxAttachments: 1},
+		data:   `51-["msg",{"_placeholder":true,"num":0},{"_placeholder":true,"num":1}]`,
+		limit:  "MaxAttachments",
+	},
+	{
+		name:   "attachment size",
+		limits: Limits{MaxAttachmentSize: 2},
+		data:   `51-["msg",{"_placeholder":true,"num":0}]` + "\n\x01\x02\x03",
+		limit:  "MaxAttachmentSize",
+	},
+	{
+		name:   "depth",
+		limits: Limits{MaxDepth: 3},
+		data:   `2["msg",[[["deep"]]]]`,
+		limit:  "MaxDepth",
+	},
+	{
+		name:   "depth fits",
+		limits: Limits{MaxDepth: 3},
+		data:   `2["msg",[{"a":"[[[["}]]`,
+	},
+	{
+		name:   "connect depth",
+		limits: Limits{MaxDepth: 1},
+		data:   `0{"a":{"b":1}}`,
+		limit:  "MaxDepth",
+	},
+	{
+		name:   "namespace",
+		limits: Limits{MaxNamespaceLength: 4},
+		data:   `2/admin,["msg"]`,
+		limit:  "MaxNamespaceLength",
+	},
+	{
+		name:   "args",
+		limits: Limits{MaxArgs: 2},
+		data:   `2["msg",1,2]`,
+		limit:  "MaxArgs",
+	},
+	{
+		name:   "args fits",
+		limits: Limits{MaxArgs: 2},
+		data:   `2["msg",{"a":[1,2,3],"b":"x,y"}]`,
+	},
+}
+
+func TestWithLimits(t *testing.T) {
+	for _, test := range limitsTests {
+		t.Run(test.name, func(t *testing.T) {
+			var message Packet
+			err := Unmarshal([]byte(test.data), &message, WithLimits(test.limits))
+			if test.limit == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			var limitErr *LimitError
+			require.True(t, errors.As(err, &limitErr), "%v", err)
+			assert.Equal(t, test.limit, limitErr.Limit)
+		})
+	}
+
+	t.Run("frames", func(t *testing.T) {
+		var message Packet
+		err := UnmarshalFrames(`51-["msg",{"_placeholder":true,"num":0}]`, [][]byte{{1, 2, 3}}, &message,
+			WithLimits(Limits{MaxAttachmentSize: 2}))
+
+		var limitErr *LimitError
+		require.True(t, errors.As(err, &limitErr))
+		assert.Equal(t, "MaxAttachmentSize", limitErr.Limit)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		dec := NewDecoder(&frameSource{
+			frames: []frame{
+				{Type: TextFrame, Data: []byte(`52-["msg",{"_placeholder":true,"num":0},{"_placeholder":true,"num":1}]`)},
+				{Type: BinaryFrame, Data: []byte(strings.Repeat("a", 10))},
+				{Type: BinaryFrame, Data: []byte(strings.Repeat("b", 10))},
+				{Type: TextFrame, Data: []byte(`1`)},
+			},
+		}, WithLimits(Limits{MaxPacketSize: 80}))
+
+		var message Packet
+		err := dec.Decode(&message)
+
+		var limitErr *LimitError
+		require.True(t, errors.As(err, &limitErr))
+		assert.Equal(t, "MaxPacketSize", limitErr.Limit)
+
+		require.NoError(t, dec.Decode(&message))
+		assert.Equal(t, Header{Type: Disconnect}, message.Header)
+	})
+
+	t.Run("message", func(t *testing.T) {
+		err := &LimitError{Limit: "MaxDepth", Max: 32}
+		assert.Equal(t, "socket.io packet exceeds MaxDepth of 32", err.Error())
+	})
+}
@@ -0,0 +1,235 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	parser "github.com/sshaplygin/go-socket.io-parser"
+)
+
+// decoder reads msgpack values into the same types as socket.io JSON parser
+// produces: integers as int when they fit, otherwise float64, bin as *Buffer.
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) value(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, errMaxDepth
+	}
+
+	c, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c < 0x80:
+		return int(c), nil
+	case c >= 0xe0:
+		return int(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return d.mapValue(int(c&0x0f), depth)
+	case c&0xf0 == 0x90:
+		return d.array(int(c&0x0f), depth)
+	case c&0xe0 == 0xa0:
+		return d.string(int(c & 0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.length(c - 0xc4)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := d.bytes(n)
+		if err != nil {
+			return nil, err
+		}
+
+		return &parser.Buffer{Data: append([]byte{}, data...)}, nil
+	case 0xca:
+		u, err := d.uint(4)
+
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := d.uint(8)
+
+		return math.Float64frombits(u), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.uint(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if u > math.MaxInt64 || int64(u) != int64(int(u)) {
+			return float64(u), nil
+		}
+
+		return int(u), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		u, err := d.uint(size)
+		if err != nil {
+			return nil, err
+		}
+
+		// sign extension of the value of size bytes.
+		shift := 64 - 8*uint(size)
+		i := int64(u<<shift) >> shift
+		if i != int64(int(i)) {
+			return float64(i), nil
+		}
+
+		return int(i), nil
+	case 0xd4:
+		// notepack.io encodes undefined as fixext 1 of type 0.
+		ext, err := d.bytes(2)
+		if err != nil {
+			return nil, err
+		}
+		if ext[0] != 0 {
+			return nil, fmt.Errorf("%w: unsupported extension type %d", ErrInvalidFormat, int8(ext[0]))
+		}
+
+		return nil, nil
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.length(c - 0xd9)
+		if err != nil {
+			return nil, err
+		}
+
+		return d.string(n)
+	case 0xdc, 0xdd:
+		n, err := d.length(c - 0xdc + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		return d.array(n, depth)
+	case 0xde, 0xdf:
+		n, err := d.length(c - 0xde + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		return d.mapValue(n, depth)
+	}
+
+	return nil, fmt.Errorf("%w: unsupported format 0x%02x at offset %d", ErrInvalidFormat, c, d.pos-1)
+}
+
+func (d *decoder) array(n, depth int) (interface{}, error) {
+	// every element takes at least one byte.
+	if n > len(d.data)-d.pos {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	ret := make([]interface{}, n)
+	for i := range ret {
+		var err error
+		if ret[i], err = d.value(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
+func (d *decoder) mapValue(n, depth int) (interface{}, error) {
+	// every key and value takes at least one byte.
+	if n > (len(d.data)-d.pos)/2 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	ret := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: map key is not a string", ErrInvalidFormat)
+		}
+
+		if ret[key], err = d.value(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
+func (d *decoder) string(n int) (interface{}, error) {
+	data, err := d.bytes(n)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(data), nil
+}
+
+// length reads length of 1<<size bytes.
+func (d *decoder) length(size byte) (int, error) {
+	u, err := d.uint(1 << size)
+	if err != nil {
+		return 0, err
+	}
+	if u > uint64(len(d.data)) {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	return int(u), nil
+}
+
+// uint reads big endian unsigned integer of size bytes.
+func (d *decoder) uint(size int) (uint64, error) {
+	data, err := d.bytes(size)
+	if err != nil {
+		return 0, err
+	}
+
+	switch size {
+	case 1:
+		return uint64(data[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(data)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(data)), nil
+	}
+
+	return binary.BigEndian.Uint64(data), nil
+}
+
+func (d *decoder) bytes(n int) ([]byte, error) {
+	if n > len(d.data)-d.pos {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	data := d.data[d.pos : d.pos+n]
+	d.pos += n
+
+	return data, nil
+}
+
+func (d *decoder) byte() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	c := d.data[d.pos]
+	d.pos++
+
+	return c, nil
+}
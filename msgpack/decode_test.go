@@ -0,0 +1,82 @@
+package msgpack
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	parser "github.com/sshaplygin/go-socket.io-parser"
+)
+
+func TestDecoder_value(t *testing.T) {
+	tests := []struct {
+		name  string
+		hex   string
+		value interface{}
+	}{
+		{"nil", "c0", nil},
+		{"undefined", "d40000", nil},
+		{"true", "c3", true},
+		{"positive fixint", "7f", 127},
+		{"negative fixint", "e0", -32},
+		{"uint16", "cd0100", 256},
+		{"uint64", "cfffffffffffffffff", float64(1<<64 - 1)},
+		{"int16", "d1ff7f", -129},
+		{"int64", "d3ffffffff7fffffff", -2147483649},
+		{"float32", "ca3fc00000", 1.5},
+		{"float64", "cb400c000000000000", 3.5},
+		{"str16", "da0003616263", "abc"},
+		{"str32", "db00000001" + "61", "a"},
+		{"bin32", "c60000000101", &parser.Buffer{Data: []byte{1}}},
+		{"array16", "dc000201c0", []interface{}{1, nil}},
+		{"map16", "de0001a16101", map[string]interface{}{"a": 1}},
+		{"map32", "df00000001a16190", map[string]interface{}{"a": []interface{}{}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := hex.DecodeString(test.hex)
+			require.NoError(t, err)
+
+			d := decoder{data: data}
+			v, err := d.value(0)
+			require.NoError(t, err)
+			assert.Equal(t, test.value, v)
+			assert.Equal(t, len(data), d.pos)
+		})
+	}
+}
+
+func TestDecoder_value_invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		hex  string
+		err  error
+	}{
+		{"reserved", "c1", ErrInvalidFormat},
+		{"extension", "d40100", ErrInvalidFormat},
+		{"map key", "810101", ErrInvalidFormat},
+		{"short string", "a3616263"[:6], io.ErrUnexpectedEOF},
+		{"huge array", "ddffffffff", io.ErrUnexpectedEOF},
+		{"huge map", "dfffffffff", io.ErrUnexpectedEOF},
+		{"huge bin", "c6ffffffff", io.ErrUnexpectedEOF},
+		{"short float", "cb00", io.ErrUnexpectedEOF},
+		{"depth", strings.Repeat("91", maxDepth+2), ErrInvalidFormat},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := hex.DecodeString(test.hex)
+			require.NoError(t, err)
+
+			d := decoder{data: data}
+			_, err = d.value(0)
+			assert.True(t, errors.Is(err, test.err), err)
+		})
+	}
+}
@@ -0,0 +1,358 @@
+package msgpack
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	parser "github.com/sshaplygin/go-socket.io-parser"
+)
+
+// maxDepth of nested values, the same as encoding/json has.
+const maxDepth = 10000
+
+var (
+	bufferType    = reflect.TypeOf(parser.Buffer{})
+	numberType    = reflect.TypeOf(json.Number(""))
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	errMaxDepth   = fmt.Errorf("%w: exceeded max depth", ErrInvalidFormat)
+)
+
+// appendValue appends msgpack encoding of v in the same way as notepack.io
+// encodes JSON-like value: struct fields are named by json tags, integral
+// numbers use the smallest integer format and Buffer or []byte become bin.
+func appendValue(b []byte, v reflect.Value, depth int) ([]byte, error) {
+	if depth > maxDepth {
+		return nil, errMaxDepth
+	}
+
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return append(b, 0xc0), nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return append(b, 0xc0), nil
+	}
+
+	switch {
+	case v.Type() == bufferType:
+		return appendBin(b, v.Interface().(parser.Buffer).Data), nil
+	case v.Type() == numberType:
+		return appendNumber(b, json.Number(v.String()))
+	case v.Type().Implements(marshalerType):
+		return appendMarshaler(b, v.Interface().(json.Marshaler), depth)
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return append(b, 0xc3), nil
+		}
+
+		return append(b, 0xc2), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendInt(b, v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return appendUint(b, v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return appendFloat(b, v.Float()), nil
+	case reflect.String:
+		return appendString(b, v.String()), nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return append(b, 0xc0), nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return appendBin(b, v.Bytes()), nil
+		}
+		fallthrough
+	case reflect.Array:
+		b = appendLen(b, v.Len(), 0x90, 0xdc, 0xdd)
+		for i := 0; i < v.Len(); i++ {
+			var err error
+			if b, err = appendValue(b, v.Index(i), depth+1); err != nil {
+				return nil, err
+			}
+		}
+
+		return b, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return append(b, 0xc0), nil
+		}
+
+		return appendMap(b, v, depth)
+	case reflect.Struct:
+		return appendStruct(b, v, depth)
+	}
+
+	return nil, fmt.Errorf("%w: unsupported type %s", ErrInvalidFormat, v.Type())
+}
+
+func appendMarshaler(b []byte, m json.Marshaler, depth int) ([]byte, error) {
+	data, err := m.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+
+	var v interface{}
+	if err = dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return appendValue(b, reflect.ValueOf(v), depth)
+}
+
+func appendNumber(b []byte, n json.Number) ([]byte, error) {
+	if i, err := strconv.ParseInt(n.String(), 10, 64); err == nil {
+		return appendInt(b, i), nil
+	}
+	if u, err := strconv.ParseUint(n.String(), 10, 64); err == nil {
+		return appendUint(b, u), nil
+	}
+
+	f, err := n.Float64()
+	if err != nil {
+		return nil, err
+	}
+
+	return appendFloat(b, f), nil
+}
+
+func appendMap(b []byte, v reflect.Value, depth int) ([]byte, error) {
+	keys := make([]string, 0, v.Len())
+	values := make(map[string]reflect.Value, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		var key string
+		switch k := iter.Key(); k.Kind() {
+		case reflect.String:
+			key = k.String()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			key = strconv.FormatInt(k.Int(), 10)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			key = strconv.FormatUint(k.Uint(), 10)
+		default:
+			return nil, fmt.Errorf("%w: unsupported map key %s", ErrInvalidFormat, k.Type())
+		}
+
+		keys = append(keys, key)
+		values[key] = iter.Value()
+	}
+
+	// keys are sorted as encoding/json does, so encoding is stable.
+	sort.Strings(keys)
+
+	b = appendLen(b, len(keys), 0x80, 0xde, 0xdf)
+	for _, key := range keys {
+		var err error
+		b = appendString(b, key)
+		if b, err = appendValue(b, values[key], depth+1); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+type field struct {
+	name  string
+	value reflect.Value
+}
+
+func appendStruct(b []byte, v reflect.Value, depth int) ([]byte, error) {
+	fields := structFields(v, nil)
+
+	b = appendLen(b, len(fields), 0x80, 0xde, 0xdf)
+	for _, f := range fields {
+		var err error
+		b = appendString(b, f.name)
+		if b, err = appendValue(b, f.value, depth+1); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+// structFields returns exported fields of v named by json tags, fields of
+// embedded structs without name are promoted.
+func structFields(v reflect.Value, fields []field) []field {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts := tag, ""
+		if idx := strings.IndexByte(tag, ','); idx >= 0 {
+			name, opts = tag[:idx], tag[idx+1:]
+		}
+
+		fv := v.Field(i)
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+
+			if ft.Kind() == reflect.Struct {
+				if fv.Kind() == reflect.Ptr {
+					if fv.IsNil() {
+						continue
+					}
+					fv = fv.Elem()
+				}
+
+				fields = structFields(fv, fields)
+
+				continue
+			}
+		}
+
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+
+		if strings.Contains(","+opts+",", ",omitempty,") && isEmptyValue(fv) {
+			continue
+		}
+
+		fields = append(fields, field{name: name, value: fv})
+	}
+
+	return fields
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+
+	return false
+}
+
+func appendUint(b []byte, u uint64) []byte {
+	switch {
+	case u < 0x80:
+		return append(b, byte(u))
+	case u <= math.MaxUint8:
+		return append(b, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		return appendUint16(append(b, 0xcd), uint16(u))
+	case u <= math.MaxUint32:
+		return appendUint32(append(b, 0xce), uint32(u))
+	}
+
+	return appendUint64(append(b, 0xcf), u)
+}
+
+func appendInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0:
+		return appendUint(b, uint64(i))
+	case i >= -0x20:
+		return append(b, byte(i))
+	case i >= math.MinInt8:
+		return append(b, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		return appendUint16(append(b, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		return appendUint32(append(b, 0xd2), uint32(i))
+	}
+
+	return appendUint64(append(b, 0xd3), uint64(i))
+}
+
+// appendFloat encodes integral float as integer, as JavaScript number is.
+func appendFloat(b []byte, f float64) []byte {
+	if f == math.Trunc(f) {
+		switch {
+		case f >= 0 && f < math.MaxUint64:
+			return appendUint(b, uint64(f))
+		case f < 0 && f >= math.MinInt64:
+			return appendInt(b, int64(f))
+		}
+	}
+
+	return appendUint64(append(b, 0xcb), math.Float64bits(f))
+}
+
+func appendString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 0x20:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = appendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = appendUint32(append(b, 0xdb), uint32(n))
+	}
+
+	return append(b, s...)
+}
+
+func appendBin(b []byte, data []byte) []byte {
+	switch n := len(data); {
+	case n <= math.MaxUint8:
+		b = append(b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		b = appendUint16(append(b, 0xc5), uint16(n))
+	default:
+		b = appendUint32(append(b, 0xc6), uint32(n))
+	}
+
+	return append(b, data...)
+}
+
+// appendLen appends header of array or map with n elements.
+func appendLen(b []byte, n int, fix, code16, code32 byte) []byte {
+	switch {
+	case n < 0x10:
+		return append(b, fix|byte(n))
+	case n <= math.MaxUint16:
+		return appendUint16(append(b, code16), uint16(n))
+	}
+
+	return appendUint32(append(b, code32), uint32(n))
+}
+
+func appendUint16(b []byte, u uint16) []byte {
+	return append(b, byte(u>>8), byte(u))
+}
+
+func appendUint32(b []byte, u uint32) []byte {
+	return append(b, byte(u>>24), byte(u>>16), byte(u>>8), byte(u))
+}
+
+func appendUint64(b []byte, u uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(u>>32)), uint32(u))
+}
@@ -0,0 +1,82 @@
+package msgpack
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	parser "github.com/sshaplygin/go-socket.io-parser"
+)
+
+type Embedded struct {
+	ID int `json:"id"`
+}
+
+type encodeStruct struct {
+	Embedded
+	Name    string         `json:"name"`
+	Skip    string         `json:"-"`
+	Empty   string         `json:"empty,omitempty"`
+	File    *parser.Buffer `json:"file"`
+	Raw     []byte         `json:"raw"`
+	private int
+}
+
+func TestAppendValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		hex   string
+	}{
+		{"nil", nil, "c0"},
+		{"bool", false, "c2"},
+		{"uint8", uint8(200), "ccc8"},
+		{"int8", int8(-100), "d09c"},
+		{"uint64", uint64(math.MaxUint64), "cfffffffffffffffff"},
+		{"int64", int64(math.MinInt64), "d38000000000000000"},
+		{"integral float", 2.0, "02"},
+		{"float", 0.5, "cb3fe0000000000000"},
+		{"float32", float32(-1.5), "cbbff8000000000000"},
+		{"str8", strings.Repeat("a", 32), "d920" + strings.Repeat("61", 32)},
+		{"str16", strings.Repeat("a", 256), "da0100" + strings.Repeat("61", 256)},
+		{"bin", []byte{1}, "c40101"},
+		{"bin16", make([]byte, 256), "c50100" + strings.Repeat("00", 256)},
+		{"nil slice", []string(nil), "c0"},
+		{"array16", make([]int, 16), "dc0010" + strings.Repeat("00", 16)},
+		{"array", [2]string{"a", "b"}, "92a161a162"},
+		{"sorted map", map[string]int{"b": 2, "a": 1}, "82a16101a16202"},
+		{"int keys", map[int]bool{1: true}, "81a131c3"},
+		{"buffer", parser.Buffer{IsBinary: true, Num: 1, Data: []byte{7}}, "c40107"},
+		{"number", json.Number("-3"), "fd"},
+		{"raw message", json.RawMessage(`{"a":[1,"b"]}`), "81a1619201a162"},
+		{"struct", encodeStruct{
+			Embedded: Embedded{ID: 1},
+			Name:     "x",
+			Skip:     "y",
+			File:     &parser.Buffer{Data: []byte{2}},
+			Raw:      []byte{3},
+		}, "84a26964" + "01" + "a46e616d65a178" + "a466696c65c40102" + "a3726177c40103"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := appendValue(nil, reflect.ValueOf(test.value), 0)
+			require.NoError(t, err)
+			assert.Equal(t, test.hex, hex.EncodeToString(b))
+		})
+	}
+
+	t.Run("unsupported", func(t *testing.T) {
+		_, err := appendValue(nil, reflect.ValueOf(make(chan int)), 0)
+		assert.Error(t, err)
+
+		_, err = appendValue(nil, reflect.ValueOf(map[float64]int{1: 1}), 0)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,10 @@
+package msgpack
+
+import "errors"
+
+var (
+	// ErrInvalidFormat is returned for malformed or unsupported msgpack data.
+	ErrInvalidFormat = errors.New("invalid msgpack data")
+	// ErrInvalidPacket is returned for msgpack value which is not a socket.io packet.
+	ErrInvalidPacket = errors.New("invalid msgpack socket.io packet")
+)
@@ -0,0 +1,173 @@
+package msgpack
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	parser "github.com/sshaplygin/go-socket.io-parser"
+)
+
+// binaryTypeShift is distance between binary packet type and its text one,
+// msgpack carries binary natively so there are no binary packet types.
+const binaryTypeShift = parser.BinaryEvent - parser.Event
+
+const defaultNamespace = "/"
+
+// Marshal packet into msgpack map with type, data, id and nsp keys, the same
+// layout as socket.io-msgpack-parser sends. Empty namespace is sent as "/".
+func Marshal(packet *parser.Packet) ([]byte, error) {
+	if packet == nil {
+		return nil, errors.New("empty packet source")
+	}
+
+	h := packet.Header
+	if !h.Type.IsValid() {
+		return nil, parser.ErrInvalidPackageType
+	}
+	if h.Type.IsBinary() {
+		h.Type -= binaryTypeShift
+	}
+
+	var data interface{}
+	if packet.Payload != nil {
+		data = packet.Payload
+	} else if packet.Data != nil {
+		data = packet.Data
+	}
+
+	size := 2
+	if data != nil {
+		size++
+	}
+	if h.IsNeedAck() {
+		size++
+	}
+
+	b := appendLen(nil, size, 0x80, 0xde, 0xdf)
+
+	b = appendString(b, "type")
+	b = appendUint(b, uint64(h.Type))
+
+	if data != nil {
+		var err error
+		b = appendString(b, "data")
+		if b, err = appendValue(b, reflect.ValueOf(data), 0); err != nil {
+			return nil, err
+		}
+	}
+
+	if h.IsNeedAck() {
+		b = appendString(b, "id")
+		b = appendUint(b, h.ID)
+	}
+
+	nsp := h.Namespace
+	if nsp == "" {
+		nsp = defaultNamespace
+	}
+	b = appendString(b, "nsp")
+	b = appendString(b, nsp)
+
+	return b, nil
+}
+
+// Unmarshal packet from msgpack data checking it as socket.io-msgpack-parser
+// does. Namespace "/" is decoded as empty one, unknown keys are ignored.
+func Unmarshal(data []byte, packet *parser.Packet) error {
+	if packet == nil {
+		return errors.New("empty output packet destination")
+	}
+
+	d := decoder{data: data}
+	v, err := d.value(0)
+	if err != nil {
+		return err
+	}
+	if d.pos != len(data) {
+		return fmt.Errorf("%w: trailing data at offset %d", ErrInvalidFormat, d.pos)
+	}
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%w: packet is not a map", ErrInvalidPacket)
+	}
+
+	t, ok := m["type"].(int)
+	if !ok || t < int(parser.Connect) || t > int(parser.Error) {
+		return fmt.Errorf("%w: invalid packet type", ErrInvalidPacket)
+	}
+
+	nsp, ok := m["nsp"].(string)
+	if !ok {
+		return fmt.Errorf("%w: invalid namespace", ErrInvalidPacket)
+	}
+	if nsp == defaultNamespace {
+		nsp = ""
+	}
+
+	message := parser.Packet{
+		Header: parser.Header{
+			Type:      parser.Type(t),
+			Namespace: nsp,
+		},
+	}
+
+	if id, ok := m["id"]; ok {
+		n, ok := id.(int)
+		if !ok || n < 0 {
+			return fmt.Errorf("%w: invalid packet id", ErrInvalidPacket)
+		}
+
+		message.Header.ID = uint64(n)
+		message.Header.HasID = true
+	}
+
+	if err = decodeData(&message, m["data"]); err != nil {
+		return err
+	}
+
+	*packet = message
+
+	return nil
+}
+
+// decodeData stores data by the packet type rules of socket.io-msgpack-parser.
+func decodeData(message *parser.Packet, data interface{}) error {
+	switch message.Header.Type {
+	case parser.Connect:
+		if _, ok := data.(map[string]interface{}); data != nil && !ok {
+			return fmt.Errorf("%w: CONNECT payload should be a map", ErrInvalidPacket)
+		}
+
+		message.Payload = data
+	case parser.Disconnect:
+		if data != nil {
+			return fmt.Errorf("%w: DISCONNECT should not have payload", ErrInvalidPacket)
+		}
+	case parser.Error:
+		switch val := data.(type) {
+		case string:
+			message.Payload = val
+		case map[string]interface{}:
+			message.Payload = val
+			if msg, ok := val["message"].(string); ok {
+				message.Payload = &parser.ConnectErrorPayload{
+					Message: msg,
+					Data:    val["data"],
+				}
+			}
+		default:
+			return fmt.Errorf("%w: CONNECT_ERROR payload should be a map or a string", ErrInvalidPacket)
+		}
+	default:
+		args, ok := data.([]interface{})
+		if !ok {
+			return fmt.Errorf("%w: payload should be an array", ErrInvalidPacket)
+		}
+
+		message.Data = args
+	}
+
+	return nil
+}
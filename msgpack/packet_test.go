@@ -0,0 +1,209 @@
+package msgpack
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	parser "github.com/sshaplygin/go-socket.io-parser"
+)
+
+// vector is msgpack encoding of packet in socket.io-msgpack-parser layout,
+// keys are in order of socket.io packets: type, data, id and nsp.
+type vector struct {
+	Name       string `json:"name"`
+	Hex        string `json:"hex"`
+	DecodeOnly bool   `json:"decode_only"`
+}
+
+var vectorPackets = map[string]parser.Packet{
+	"connect": {
+		Header: parser.Header{Type: parser.Connect},
+	},
+	"connect auth": {
+		Header:  parser.Header{Type: parser.Connect, Namespace: "/admin"},
+		Payload: map[string]interface{}{"token": "123"},
+	},
+	"connect sid": {
+		Header:  parser.Header{Type: parser.Connect},
+		Payload: map[string]interface{}{"sid": "oSO0OpakMV_3jnilAAAA"},
+	},
+	"disconnect": {
+		Header: parser.Header{Type: parser.Disconnect, Namespace: "/admin"},
+	},
+	"event": {
+		Header: parser.Header{Type: parser.Event},
+		Data: []interface{}{
+			"hello", 1, -5, 3.5, true, nil,
+			map[string]interface{}{"a": []interface{}{1, 2}},
+		},
+	},
+	"event id": {
+		Header: parser.Header{Type: parser.Event, Namespace: "/admin", ID: 13, HasID: true},
+		Data:   []interface{}{"msg"},
+	},
+	"ack": {
+		Header: parser.Header{Type: parser.Ack, ID: 300, HasID: true},
+		Data:   []interface{}{},
+	},
+	"event binary": {
+		Header: parser.Header{Type: parser.Event},
+		Data: []interface{}{
+			"upload",
+			&parser.Buffer{Data: []byte{1, 2, 3}},
+			map[string]interface{}{"file": &parser.Buffer{Data: []byte("hi")}},
+		},
+	},
+	"event numbers": {
+		Header: parser.Header{Type: parser.Event},
+		Data:   []interface{}{"n", 255, 256, 65536, 4294967296, -32, -129, -40000, -2147483649},
+	},
+	"event long string": {
+		Header: parser.Header{Type: parser.Event},
+		Data:   []interface{}{strings.Repeat("x", 40)},
+	},
+	"connect error": {
+		Header: parser.Header{Type: parser.Error},
+		Payload: &parser.ConnectErrorPayload{
+			Message: "Not authorized",
+			Data:    map[string]interface{}{"code": 401},
+		},
+	},
+	"connect error string": {
+		Header:  parser.Header{Type: parser.Error, Namespace: "/admin"},
+		Payload: "Not authorized",
+	},
+	"client event": {
+		Header: parser.Header{Type: parser.Event, ID: 1, HasID: true},
+		Data:   []interface{}{"x"},
+	},
+}
+
+func loadVectors(t *testing.T) []vector {
+	data, err := ioutil.ReadFile("testdata/vectors.json")
+	require.NoError(t, err)
+
+	var vectors []vector
+	require.NoError(t, json.Unmarshal(data, &vectors))
+	require.Len(t, vectors, len(vectorPackets))
+
+	return vectors
+}
+
+func TestMarshal(t *testing.T) {
+	for _, v := range loadVectors(t) {
+		if v.DecodeOnly {
+			continue
+		}
+
+		t.Run(v.Name, func(t *testing.T) {
+			packet, ok := vectorPackets[v.Name]
+			require.True(t, ok)
+
+			data, err := Marshal(&packet)
+			require.NoError(t, err)
+			assert.Equal(t, v.Hex, hex.EncodeToString(data))
+		})
+	}
+
+	t.Run("binary type", func(t *testing.T) {
+		data, err := Marshal(&parser.Packet{
+			Header: parser.Header{Type: parser.BinaryEvent},
+			Data:   []interface{}{"x"},
+		})
+		require.NoError(t, err)
+
+		var packet parser.Packet
+		require.NoError(t, Unmarshal(data, &packet))
+		assert.Equal(t, parser.Event, packet.Header.Type)
+	})
+
+	t.Run("invalid type", func(t *testing.T) {
+		_, err := Marshal(&parser.Packet{Header: parser.Header{Type: parser.BinaryAck + 1}})
+		assert.Equal(t, parser.ErrInvalidPackageType, err)
+	})
+
+	t.Run("nil packet", func(t *testing.T) {
+		_, err := Marshal(nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestUnmarshal(t *testing.T) {
+	for _, v := range loadVectors(t) {
+		t.Run(v.Name, func(t *testing.T) {
+			data, err := hex.DecodeString(v.Hex)
+			require.NoError(t, err)
+
+			var packet parser.Packet
+			require.NoError(t, Unmarshal(data, &packet))
+			assert.Equal(t, vectorPackets[v.Name], packet)
+		})
+	}
+}
+
+func TestUnmarshal_invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		hex  string
+		err  error
+	}{
+		{"empty", "", io.ErrUnexpectedEOF},
+		{"not map", "92a47479706500", ErrInvalidPacket},
+		{"missing type", "81a36e7370a12f", ErrInvalidPacket},
+		{"binary type", "82a47479706505a36e7370a12f", ErrInvalidPacket},
+		{"missing nsp", "81a47479706500", ErrInvalidPacket},
+		{"nsp not string", "82a47479706500a36e737001", ErrInvalidPacket},
+		{"connect array", "83a47479706500a46461746190a36e7370a12f", ErrInvalidPacket},
+		{"disconnect data", "83a47479706501a46461746190a36e7370a12f", ErrInvalidPacket},
+		{"event without data", "82a47479706502a36e7370a12f", ErrInvalidPacket},
+		{"event map", "83a47479706502a46461746180a36e7370a12f", ErrInvalidPacket},
+		{"error number", "83a47479706504a46461746101a36e7370a12f", ErrInvalidPacket},
+		{"negative id", "84a47479706502a46461746190a26964ffa36e7370a12f", ErrInvalidPacket},
+		{"float id", "84a47479706502a46461746190a26964cb3ff8000000000000a36e7370a12f", ErrInvalidPacket},
+		{"trailing data", "82a47479706500a36e7370a12f00", ErrInvalidFormat},
+		{"truncated", "82a47479706500a36e7370a1", io.ErrUnexpectedEOF},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := hex.DecodeString(test.hex)
+			require.NoError(t, err)
+
+			var packet parser.Packet
+			err = Unmarshal(data, &packet)
+			assert.True(t, errors.Is(err, test.err), err)
+		})
+	}
+}
+
+func TestPacket_DecodeArgs(t *testing.T) {
+	var packet parser.Packet
+	data, err := Marshal(&parser.Packet{
+		Header: parser.Header{Type: parser.Event},
+		Data: []interface{}{
+			"upload",
+			[]byte{1, 2, 3},
+			map[string]interface{}{"file": parser.Buffer{Data: []byte("hi")}},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, Unmarshal(data, &packet))
+
+	var (
+		buffer parser.Buffer
+		args   struct {
+			File parser.Buffer `json:"file"`
+		}
+	)
+	require.NoError(t, packet.DecodeArgs(nil, &buffer, &args))
+	assert.Equal(t, []byte{1, 2, 3}, buffer.Data)
+	assert.Equal(t, []byte("hi"), args.File.Data)
+}
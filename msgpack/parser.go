@@ -0,0 +1,93 @@
+package msgpack
+
+import (
+	"errors"
+	"io"
+
+	parser "github.com/sshaplygin/go-socket.io-parser"
+)
+
+var _ parser.Parser = msgpackParser{}
+
+type msgpackParser struct{}
+
+// NewParser returns parser.Parser sending every packet as a single binary
+// frame, compatible with socket.io-msgpack-parser.
+func NewParser() parser.Parser {
+	return msgpackParser{}
+}
+
+// NewEncoder returns Encoder writing to w.
+func (msgpackParser) NewEncoder(w io.Writer) parser.PacketEncoder {
+	return NewEncoder(w)
+}
+
+// NewDecoder returns Decoder reading from r.
+func (msgpackParser) NewDecoder(r io.Reader) parser.PacketDecoder {
+	return NewDecoder(r)
+}
+
+// Encoder writes msgpack packets to an output stream.
+type Encoder struct {
+	fw parser.FrameWriter
+}
+
+// NewEncoder returns a new encoder that writes to w.
+// If w implements parser.FrameWriter, packets are written as binary frames.
+// Otherwise w is a byte stream and frames are written by parser.NewFrameWriter.
+func NewEncoder(w io.Writer) *Encoder {
+	fw, ok := w.(parser.FrameWriter)
+	if !ok {
+		fw = parser.NewFrameWriter(w)
+	}
+
+	return &Encoder{
+		fw: fw,
+	}
+}
+
+// Encode writes packet by a single call.
+func (e *Encoder) Encode(packet *parser.Packet) error {
+	frame, err := Marshal(packet)
+	if err != nil {
+		return err
+	}
+
+	return e.fw.WriteFrame(parser.BinaryFrame, frame)
+}
+
+// Decoder reads msgpack packets from an input stream.
+type Decoder struct {
+	fr parser.FrameReader
+}
+
+// NewDecoder returns a new decoder that reads from r.
+// If r implements parser.FrameReader, text frames are rejected. Otherwise r is
+// a byte stream and frames are read by parser.NewFrameReader.
+func NewDecoder(r io.Reader) *Decoder {
+	fr, ok := r.(parser.FrameReader)
+	if !ok {
+		fr = parser.NewFrameReader(r)
+	}
+
+	return &Decoder{
+		fr: fr,
+	}
+}
+
+// Decode reads the next frame and stores packet from it.
+func (d *Decoder) Decode(packet *parser.Packet) error {
+	if packet == nil {
+		return errors.New("empty output packet destination")
+	}
+
+	ft, frame, err := d.fr.NextFrame()
+	if err != nil {
+		return err
+	}
+	if ft != parser.BinaryFrame {
+		return parser.ErrShouldBinaryPackageType
+	}
+
+	return Unmarshal(frame, packet)
+}
@@ -0,0 +1,112 @@
+package msgpack
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	parser "github.com/sshaplygin/go-socket.io-parser"
+)
+
+type frame struct {
+	Type parser.FrameType
+	Data []byte
+}
+
+type frameConn struct {
+	frames []frame
+}
+
+func (f *frameConn) Write(p []byte) (int, error) {
+	return 0, errors.New("unexpected write")
+}
+
+func (f *frameConn) WriteFrame(ft parser.FrameType, p []byte) error {
+	f.frames = append(f.frames, frame{Type: ft, Data: append([]byte(nil), p...)})
+	return nil
+}
+
+func (f *frameConn) Read(p []byte) (int, error) {
+	return 0, errors.New("unexpected read")
+}
+
+func (f *frameConn) NextFrame() (parser.FrameType, []byte, error) {
+	if len(f.frames) == 0 {
+		return 0, nil, io.EOF
+	}
+
+	next := f.frames[0]
+	f.frames = f.frames[1:]
+
+	return next.Type, next.Data, nil
+}
+
+func TestNewParser(t *testing.T) {
+	p := NewParser()
+
+	packets := []parser.Packet{
+		vectorPackets["event binary"],
+		vectorPackets["connect error"],
+	}
+
+	var conn frameConn
+	enc := p.NewEncoder(&conn)
+	for idx := range packets {
+		require.NoError(t, enc.Encode(&packets[idx]))
+	}
+
+	require.Len(t, conn.frames, len(packets))
+	for _, f := range conn.frames {
+		assert.Equal(t, parser.BinaryFrame, f.Type)
+	}
+
+	dec := p.NewDecoder(&conn)
+	for _, expected := range packets {
+		var packet parser.Packet
+		require.NoError(t, dec.Decode(&packet))
+		assert.Equal(t, expected, packet)
+	}
+
+	var packet parser.Packet
+	assert.Equal(t, io.EOF, dec.Decode(&packet))
+
+	t.Run("text frame", func(t *testing.T) {
+		conn := frameConn{frames: []frame{{Type: parser.TextFrame, Data: []byte("2[]")}}}
+
+		var packet parser.Packet
+		assert.Equal(t, parser.ErrShouldBinaryPackageType, NewDecoder(&conn).Decode(&packet))
+	})
+
+	t.Run("plain stream", func(t *testing.T) {
+		packets := []parser.Packet{
+			vectorPackets["event id"],
+			{
+				Header: parser.Header{Type: parser.Event},
+				Data:   []interface{}{"large", &parser.Buffer{Data: bytes.Repeat([]byte{1}, 100<<10)}},
+			},
+			vectorPackets["event binary"],
+		}
+
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		for idx := range packets {
+			require.NoError(t, enc.Encode(&packets[idx]))
+		}
+
+		// frames are split between reads.
+		dec := NewDecoder(iotest.OneByteReader(&buf))
+		for _, expected := range packets {
+			var message parser.Packet
+			require.NoError(t, dec.Decode(&message))
+			assert.Equal(t, expected, message)
+		}
+
+		var message parser.Packet
+		assert.Equal(t, io.EOF, dec.Decode(&message))
+	})
+}
@@ -0,0 +1,5 @@
+//go:build !race
+
+package go_socketio_parser
+
+const raceEnabled = false
@@ -0,0 +1,87 @@
+package go_socketio_parser
+
+// Option configures packet encoding and decoding.
+type Option func(*options)
+
+type options struct {
+	validate bool
+	limits   Limits
+	protocol ProtocolVersion
+	base64   bool
+	zeroCopy bool
+}
+
+var defaultOptions = options{
+	protocol: ProtocolV5,
+}
+
+func newOptions(opts []Option) options {
+	// options escape to heap when they are passed to Option.
+	if len(opts) == 0 {
+		return defaultOptions
+	}
+
+	o := defaultOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// ProtocolVersion of socket.io protocol.
+type ProtocolVersion byte
+
+// supported socket.io protocol versions.
+const (
+	// ProtocolV4 is used by socket.io 2.x: ERROR packet carries plain string,
+	// CONNECT packet carries query parameters inside namespace and has no payload.
+	// Default namespace "/" is connected implicitly, it is not written and is
+	// decoded as empty Header.Namespace.
+	ProtocolV4 ProtocolVersion = 4
+	// ProtocolV5 is used by socket.io 3.x and later, it is the default one.
+	ProtocolV5 ProtocolVersion = 5
+)
+
+// WithProtocolVersion switches encoding and decoding to the protocol version.
+func WithProtocolVersion(version ProtocolVersion) Option {
+	return func(o *options) {
+		o.protocol = version
+	}
+}
+
+// WithBase64Binary enables text-only mode for transports without binary
+// support: Buffer values are inlined as {"base64":true,"data":"<base64>"}
+// instead of binary attachments, and such objects are decoded back to Buffer.
+func WithBase64Binary() Option {
+	return func(o *options) {
+		o.base64 = true
+	}
+}
+
+// WithZeroCopy decodes packets without copying the input: Buffer.Data and
+// the namespace share memory with the input frame, arguments are kept as
+// json.RawMessage subslices of it unless they contain buffers. The caller
+// of Unmarshal and UnmarshalFrames must not change or reuse the input after
+// decoding. Decoder copies every frame once, since readers reuse them.
+func WithZeroCopy() Option {
+	return func(o *options) {
+		o.zeroCopy = true
+	}
+}
+
+// WithValidation checks every encoded and decoded packet by Packet.Validate.
+func WithValidation() Option {
+	return func(o *options) {
+		o.validate = true
+	}
+}
+
+// check validates packet when validation is enabled.
+func (o options) check(p *Packet) error {
+	if !o.validate {
+		return nil
+	}
+
+	return p.validate(o.protocol)
+}
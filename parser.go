@@ -0,0 +1,51 @@
+package go_socketio_parser
+
+import (
+	"io"
+)
+
+// PacketEncoder writes socket.io packets in the parser wire format.
+type PacketEncoder interface {
+	Encode(packet *Packet) error
+}
+
+// PacketDecoder reads socket.io packets in the parser wire format.
+type PacketDecoder interface {
+	Decode(packet *Packet) error
+}
+
+// Parser is a wire format of socket.io packets, the same as the parser option
+// of socket.io server. Transports should depend on Parser instead of Marshal
+// and Unmarshal, so the format can be replaced (e.g. by msgpack).
+type Parser interface {
+	NewEncoder(w io.Writer) PacketEncoder
+	NewDecoder(r io.Reader) PacketDecoder
+}
+
+var (
+	_ PacketEncoder = (*Encoder)(nil)
+	_ PacketDecoder = (*Decoder)(nil)
+)
+
+// jsonParser is the default socket.io parser with JSON-stringified payload.
+type jsonParser struct {
+	opts []Option
+}
+
+// NewParser returns the default JSON parser, opts are applied to every encoder
+// and decoder.
+func NewParser(opts ...Option) Parser {
+	return &jsonParser{
+		opts: opts,
+	}
+}
+
+// NewEncoder returns Encoder writing to w.
+func (p *jsonParser) NewEncoder(w io.Writer) PacketEncoder {
+	return NewEncoder(w, p.opts...)
+}
+
+// NewDecoder returns Decoder reading from r.
+func (p *jsonParser) NewDecoder(r io.Reader) PacketDecoder {
+	return NewDecoder(r, p.opts...)
+}
@@ -0,0 +1,47 @@
+package go_socketio_parser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewParser(t *testing.T) {
+	var parser Parser = NewParser()
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var rec frameRecorder
+			err := parser.NewEncoder(&rec).Encode(&Packet{
+				Header:  test.Header,
+				Data:    test.Data,
+				Payload: test.Payload,
+			})
+			require.NoError(t, err)
+
+			var message Packet
+			require.NoError(t, parser.NewDecoder(&frameSource{frames: rec.frames}).Decode(&message))
+			require.Equal(t, len(test.Data), len(message.Data))
+
+			assert.Equal(t, test.Header, message.Header)
+			assert.Equal(t, test.Payload, message.Payload)
+			for idx, data := range test.Data {
+				assert.Equal(t, data, message.Data[idx])
+			}
+		})
+	}
+
+	t.Run("options", func(t *testing.T) {
+		parser := NewParser(WithValidation())
+
+		var rec frameRecorder
+		err := parser.NewEncoder(&rec).Encode(&Packet{Header: Header{Type: Event}})
+		assert.True(t, errors.Is(err, ErrInvalidPacket))
+
+		var message Packet
+		err = parser.NewDecoder(&frameSource{frames: []frame{{Type: TextFrame, Data: []byte(`2[null]`)}}}).Decode(&message)
+		assert.True(t, errors.Is(err, ErrInvalidPacket))
+	})
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package go_socketio_parser
+
+// raceEnabled is set when tests are run with race detector, which makes
+// sync.Pool drop values, so pooled encoding allocates.
+const raceEnabled = true
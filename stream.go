@@ -1,33 +1,221 @@
 package go_socketio_parser
 
 import (
+	"errors"
 	"io"
 )
 
+// FrameType of transport frame.
+type FrameType byte
+
+// transport frame types.
+const (
+	// TextFrame carries the packet header and JSON-stringified payload.
+	TextFrame FrameType = iota
+	// BinaryFrame carries a single binary attachment.
+	BinaryFrame
+)
+
+// FrameWriter is implemented by message oriented writers (e.g. websocket
+// connections) which distinguish text and binary frames.
+type FrameWriter interface {
+	WriteFrame(ft FrameType, frame []byte) error
+}
+
+// Encoder writes socket.io packets to an output stream.
 type Encoder struct {
-	w io.Writer
+	fw  FrameWriter
+	o   options
+	buf []byte
 }
 
-func NewEncoder(w io.Writer) *Encoder {
+// NewEncoder returns a new encoder that writes to w.
+// If w implements FrameWriter, frames are written with their type. Otherwise
+// w is a byte stream and frames are written by NewFrameWriter.
+func NewEncoder(w io.Writer, opts ...Option) *Encoder {
+	fw, ok := w.(FrameWriter)
+	if !ok {
+		fw = NewFrameWriter(w)
+	}
+
 	return &Encoder{
-		w: w,
+		fw: fw,
+		o:  newOptions(opts),
 	}
 }
 
+// Encode writes the text frame of packet followed by every binary attachment.
+// Each frame is passed to the underlying writer by a single call.
 func (e *Encoder) Encode(packet *Packet) error {
+	if packet == nil {
+		return errors.New("empty packet source")
+	}
+
+	buf, buffers, err := appendPacket(e.buf[:0], packet, e.o)
+	e.buf = buf
+	if err != nil {
+		return err
+	}
+
+	if err = e.fw.WriteFrame(TextFrame, e.buf); err != nil {
+		return err
+	}
+
+	for _, b := range buffers {
+		if err = e.fw.WriteFrame(BinaryFrame, b); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// FrameReader is implemented by message oriented readers (e.g. websocket
+// connections) which distinguish text and binary frames. The returned frame
+// is only valid until the next call.
+type FrameReader interface {
+	NextFrame() (FrameType, []byte, error)
+}
+
+// Decoder reads socket.io packets from an input stream. Binary packets are
+// reassembled from the text frame and the following binary frames.
 type Decoder struct {
-	r io.Reader
+	fr FrameReader
+	o  options
+
+	pending     *Packet
+	expected    uint64
+	attachments [][]byte
+	size        int
 }
 
-func NewDecoder(r io.Reader) *Decoder {
-	return &Decoder{
-		r: r,
+// NewDecoder returns a new decoder that reads from r.
+// If r implements FrameReader, frames are read with their type. Otherwise
+// r is a byte stream and frames are read by NewFrameReader.
+func NewDecoder(r io.Reader, opts ...Option) *Decoder {
+	d := &Decoder{
+		o: newOptions(opts),
+	}
+
+	if fr, ok := r.(FrameReader); ok {
+		d.fr = fr
+	} else {
+		d.fr = &streamFrameReader{r: r, limits: d.o.limits}
 	}
+
+	return d
 }
 
-func (e *Decoder) Decode(packet *Packet) error {
-	return nil
+// Decode reads frames until the next complete packet is received and stores it
+// in packet. A binary frame without pending packet returns
+// ErrShouldTextPackageType and a text frame while attachments are pending
+// returns ErrShouldBinaryPackageType, the pending packet is dropped in both cases.
+func (d *Decoder) Decode(packet *Packet) error {
+	if packet == nil {
+		return errors.New("empty output header destination")
+	}
+
+	for {
+		ft, frame, err := d.nextFrame()
+		if err != nil {
+			return err
+		}
+
+		if d.pending == nil {
+			if ft != TextFrame {
+				return ErrShouldTextPackageType
+			}
+
+			if len(frame) == 0 {
+				return &DecodeError{Section: SectionType, Err: io.ErrUnexpectedEOF}
+			}
+
+			if err = d.o.limits.checkPacketSize(len(frame)); err != nil {
+				return err
+			}
+
+			var message Packet
+			count, err := decodePacket(frame, &message, false, d.o)
+			if err != nil {
+				return err
+			}
+
+			if count == 0 {
+				if err = d.o.check(&message); err != nil {
+					return err
+				}
+
+				*packet = message
+
+				return nil
+			}
+
+			d.pending = &message
+			d.expected = count
+			d.attachments = d.attachments[:0]
+			d.size = len(frame)
+
+			continue
+		}
+
+		if ft != BinaryFrame {
+			d.reset()
+			return ErrShouldBinaryPackageType
+		}
+
+		d.size += len(frame)
+		if err = d.o.limits.checkPacketSize(d.size); err != nil {
+			d.reset()
+			return err
+		}
+		if err = d.o.limits.checkAttachmentSize(len(frame)); err != nil {
+			d.reset()
+			return err
+		}
+
+		if !d.o.zeroCopy {
+			frame = append([]byte(nil), frame...)
+		}
+		d.attachments = append(d.attachments, frame)
+		if uint64(len(d.attachments)) < d.expected {
+			continue
+		}
+
+		message := d.pending
+		err = bindBuffer(message.Data, d.attachments)
+		d.reset()
+		if err != nil {
+			return err
+		}
+
+		if err = d.o.check(message); err != nil {
+			return err
+		}
+
+		*packet = *message
+
+		return nil
+	}
+}
+
+func (d *Decoder) reset() {
+	d.pending = nil
+	d.expected = 0
+	d.attachments = d.attachments[:0]
+	d.size = 0
+}
+
+func (d *Decoder) nextFrame() (FrameType, []byte, error) {
+	ft, frame, err := d.fr.NextFrame()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// frames are only valid until the next read (FrameReader implementations
+	// reuse their buffers), so decoded packet shares memory with its own copy.
+	if d.o.zeroCopy {
+		frame = append([]byte(nil), frame...)
+	}
+
+	return ft, frame, nil
 }
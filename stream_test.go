@@ -1 +1,480 @@
 package go_socketio_parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type frame struct {
+	Type FrameType
+	Data []byte
+}
+
+type frameRecorder struct {
+	frames []frame
+}
+
+func (f *frameRecorder) Write(p []byte) (int, error) {
+	return 0, errors.New("unexpected write")
+}
+
+func (f *frameRecorder) WriteFrame(ft FrameType, p []byte) error {
+	f.frames = append(f.frames, frame{Type: ft, Data: append([]byte(nil), p...)})
+	return nil
+}
+
+type errWriter struct {
+	err error
+}
+
+func (w errWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}
+
+func TestEncoder_Encode(t *testing.T) {
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var rec frameRecorder
+
+			err := NewEncoder(&rec).Encode(&Packet{
+				Header:  test.Header,
+				Data:    test.Data,
+				Payload: test.Payload,
+			})
+			require.NoError(t, err)
+			require.NotEmpty(t, rec.frames)
+
+			frames := make([][]byte, 0, len(rec.frames))
+			for idx, f := range rec.frames {
+				assert.Equal(t, idx > 0, f.Type == BinaryFrame)
+				frames = append(frames, f.Data)
+			}
+
+			assert.Equal(t, test.Tmpl, string(bytes.Join(frames, []byte{'\n'})))
+		})
+	}
+
+	t.Run("frames", func(t *testing.T) {
+		var rec frameRecorder
+		enc := NewEncoder(&rec)
+
+		packet := &Packet{
+			Header: Header{
+				Type:      Event,
+				Namespace: "/woot",
+			},
+			Data: []interface{}{
+				"msg",
+				&Buffer{Data: []byte{1, 2}},
+				&Buffer{Data: []byte{3, 4}},
+			},
+		}
+
+		require.NoError(t, enc.Encode(packet))
+		require.NoError(t, enc.Encode(&Packet{Header: Header{Type: Disconnect}}))
+
+		assert.Equal(t, []frame{
+			{Type: TextFrame, Data: []byte(`52-/woot,["msg",{"_placeholder":true,"num":0},{"_placeholder":true,"num":1}]`)},
+			{Type: BinaryFrame, Data: []byte{1, 2}},
+			{Type: BinaryFrame, Data: []byte{3, 4}},
+			{Type: TextFrame, Data: []byte(`1`)},
+		}, rec.frames)
+	})
+
+	t.Run("writer", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		err := NewEncoder(&buf).Encode(&Packet{
+			Header: Header{Type: Event},
+			Data:   []interface{}{"msg", &Buffer{Data: []byte{1, 2}}},
+		})
+		require.NoError(t, err)
+
+		text := `51-["msg",{"_placeholder":true,"num":0}]`
+		assert.Equal(t, "\x00\x00\x00\x00\x28"+text+"\x01\x00\x00\x00\x02\x01\x02", buf.String())
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		writeErr := errors.New("closed")
+
+		err := NewEncoder(errWriter{err: writeErr}).Encode(&Packet{Header: Header{Type: Connect}})
+		assert.Equal(t, writeErr, err)
+	})
+
+	t.Run("nil packet", func(t *testing.T) {
+		err := NewEncoder(&bytes.Buffer{}).Encode(nil)
+		assert.Error(t, err)
+	})
+}
+
+type frameSource struct {
+	frames []frame
+}
+
+func (f *frameSource) Read(p []byte) (int, error) {
+	return 0, errors.New("unexpected read")
+}
+
+func (f *frameSource) NextFrame() (FrameType, []byte, error) {
+	if len(f.frames) == 0 {
+		return 0, nil, io.EOF
+	}
+
+	next := f.frames[0]
+	f.frames = f.frames[1:]
+
+	return next.Type, next.Data, nil
+}
+
+// reusingFrameSource returns every frame in the same buffer, like websocket
+// connections do.
+type reusingFrameSource struct {
+	frames []frame
+	buf    []byte
+}
+
+func (f *reusingFrameSource) Read(p []byte) (int, error) {
+	return 0, errors.New("unexpected read")
+}
+
+func (f *reusingFrameSource) NextFrame() (FrameType, []byte, error) {
+	if len(f.frames) == 0 {
+		return 0, nil, io.EOF
+	}
+
+	next := f.frames[0]
+	f.frames = f.frames[1:]
+	f.buf = append(f.buf[:0], next.Data...)
+
+	return next.Type, f.buf, nil
+}
+
+// frameStream writes frames to byte stream by NewFrameWriter.
+func frameStream(t *testing.T, frames ...frame) *bytes.Buffer {
+	var buf bytes.Buffer
+
+	fw := NewFrameWriter(&buf)
+	for _, f := range frames {
+		require.NoError(t, fw.WriteFrame(f.Type, f.Data))
+	}
+
+	return &buf
+}
+
+func TestDecoder_Decode(t *testing.T) {
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var src frameSource
+			for idx, data := range bytes.Split([]byte(test.Tmpl), []byte{'\n'}) {
+				ft := TextFrame
+				if idx > 0 {
+					ft = BinaryFrame
+				}
+
+				src.frames = append(src.frames, frame{Type: ft, Data: data})
+			}
+
+			var message Packet
+			require.NoError(t, NewDecoder(&src).Decode(&message))
+			require.Equal(t, len(test.Data), len(message.Data))
+
+			assert.Equal(t, test.Header, message.Header)
+			assert.Equal(t, test.Payload, message.Payload)
+			for idx, data := range test.Data {
+				assert.Equal(t, data, message.Data[idx])
+			}
+		})
+	}
+
+	t.Run("frames", func(t *testing.T) {
+		dec := NewDecoder(&frameSource{
+			frames: []frame{
+				{Type: TextFrame, Data: []byte(`52-/woot,["msg",{"_placeholder":true,"num":1},{"_placeholder":true,"num":0}]`)},
+				{Type: BinaryFrame, Data: []byte{1, 2}},
+				{Type: BinaryFrame, Data: []byte{3, 4}},
+				{Type: TextFrame, Data: []byte(`1`)},
+			},
+		})
+
+		var message Packet
+		require.NoError(t, dec.Decode(&message))
+
+		assert.Equal(t, Header{Type: Event, Namespace: "/woot"}, message.Header)
+		assert.Equal(t, []interface{}{
+			"msg",
+			&Buffer{IsBinary: true, Num: 1, Data: []byte{3, 4}},
+			&Buffer{IsBinary: true, Num: 0, Data: []byte{1, 2}},
+		}, message.Data)
+
+		message = Packet{}
+		require.NoError(t, dec.Decode(&message))
+		assert.Equal(t, Header{Type: Disconnect}, message.Header)
+
+		assert.Equal(t, io.EOF, dec.Decode(&message))
+	})
+
+	t.Run("binary frame first", func(t *testing.T) {
+		dec := NewDecoder(&frameSource{
+			frames: []frame{
+				{Type: BinaryFrame, Data: []byte{1, 2}},
+				{Type: TextFrame, Data: []byte(`1`)},
+			},
+		})
+
+		var message Packet
+		assert.Equal(t, ErrShouldTextPackageType, dec.Decode(&message))
+
+		require.NoError(t, dec.Decode(&message))
+		assert.Equal(t, Header{Type: Disconnect}, message.Header)
+	})
+
+	t.Run("text frame instead of attachment", func(t *testing.T) {
+		dec := NewDecoder(&frameSource{
+			frames: []frame{
+				{Type: TextFrame, Data: []byte(`51-["msg",{"_placeholder":true,"num":0}]`)},
+				{Type: TextFrame, Data: []byte(`1`)},
+				{Type: TextFrame, Data: []byte(`1`)},
+			},
+		})
+
+		var message Packet
+		assert.Equal(t, ErrShouldBinaryPackageType, dec.Decode(&message))
+
+		require.NoError(t, dec.Decode(&message))
+		assert.Equal(t, Header{Type: Disconnect}, message.Header)
+	})
+
+	t.Run("reader", func(t *testing.T) {
+		stream := frameStream(t,
+			frame{Type: TextFrame, Data: []byte(`51-["msg",{"_placeholder":true,"num":0}]`)},
+			frame{Type: BinaryFrame, Data: []byte{1, 2, 3}},
+			frame{Type: TextFrame, Data: []byte(`1`)},
+		)
+
+		// frames are split between reads.
+		dec := NewDecoder(iotest.OneByteReader(stream))
+
+		var message Packet
+		require.NoError(t, dec.Decode(&message))
+
+		assert.Equal(t, Header{Type: Event}, message.Header)
+		assert.Equal(t, []interface{}{
+			"msg",
+			&Buffer{IsBinary: true, Data: []byte{1, 2, 3}},
+		}, message.Data)
+
+		require.NoError(t, dec.Decode(&message))
+		assert.Equal(t, Header{Type: Disconnect}, message.Header)
+
+		assert.Equal(t, io.EOF, dec.Decode(&message))
+	})
+
+	t.Run("large frame", func(t *testing.T) {
+		data := bytes.Repeat([]byte{7}, 3*maxFrameChunk+1)
+		stream := frameStream(t,
+			frame{Type: TextFrame, Data: []byte(`51-["msg",{"_placeholder":true,"num":0}]`)},
+			frame{Type: BinaryFrame, Data: data},
+		)
+
+		var message Packet
+		require.NoError(t, NewDecoder(stream).Decode(&message))
+		assert.Equal(t, &Buffer{IsBinary: true, Data: data}, message.Data[1])
+	})
+
+	t.Run("truncated frame", func(t *testing.T) {
+		stream := frameStream(t, frame{Type: TextFrame, Data: []byte(`2["msg"]`)})
+		stream.Truncate(stream.Len() - 1)
+
+		var message Packet
+		assert.Equal(t, io.ErrUnexpectedEOF, NewDecoder(stream).Decode(&message))
+	})
+
+	t.Run("empty text frame", func(t *testing.T) {
+		stream := frameStream(t, frame{Type: TextFrame})
+
+		var message Packet
+		err := NewDecoder(stream).Decode(&message)
+
+		var decodeErr *DecodeError
+		require.True(t, errors.As(err, &decodeErr))
+		assert.Equal(t, SectionType, decodeErr.Section)
+		assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+
+		assert.Equal(t, Unmarshal(nil, &message), err)
+	})
+
+	t.Run("invalid frame type", func(t *testing.T) {
+		var message Packet
+		err := NewDecoder(bytes.NewReader([]byte{2, 0, 0, 0, 1, '1'})).Decode(&message)
+		assert.Equal(t, ErrInvalidFrame, err)
+	})
+
+	t.Run("frame size limit", func(t *testing.T) {
+		var message Packet
+		err := NewDecoder(bytes.NewReader([]byte{0, 0xff, 0xff, 0xff, 0xff}), WithLimits(Limits{MaxPacketSize: 1024})).Decode(&message)
+
+		var limitErr *LimitError
+		require.True(t, errors.As(err, &limitErr))
+		assert.Equal(t, "MaxPacketSize", limitErr.Limit)
+	})
+
+	t.Run("zero copy", func(t *testing.T) {
+		dec := NewDecoder(&reusingFrameSource{
+			frames: []frame{
+				{Type: TextFrame, Data: []byte(`51-/chat,["msg",{"_placeholder":true,"num":0}]`)},
+				{Type: BinaryFrame, Data: []byte{1, 2, 3}},
+				{Type: TextFrame, Data: []byte(`2/XXXXX,["XXX"]`)},
+			},
+		}, WithZeroCopy())
+
+		var message Packet
+		require.NoError(t, dec.Decode(&message))
+
+		var next Packet
+		require.NoError(t, dec.Decode(&next))
+
+		assert.Equal(t, "/chat", message.Header.Namespace)
+		assert.Equal(t, []interface{}{
+			json.RawMessage(`"msg"`),
+			&Buffer{IsBinary: true, Data: []byte{1, 2, 3}},
+		}, message.Data)
+		assert.Equal(t, "/XXXXX", next.Header.Namespace)
+	})
+
+	t.Run("zero copy reader", func(t *testing.T) {
+		dec := NewDecoder(frameStream(t,
+			frame{Type: TextFrame, Data: []byte(`51-/woot,["msg",{"_placeholder":true,"num":0}]`)},
+			frame{Type: BinaryFrame, Data: []byte{1, 2, 3}},
+			frame{Type: TextFrame, Data: []byte(`2/next,["msg"]`)},
+		), WithZeroCopy())
+
+		var message Packet
+		require.NoError(t, dec.Decode(&message))
+
+		// read buffer is reused by the next packet.
+		var next Packet
+		require.NoError(t, dec.Decode(&next))
+
+		assert.Equal(t, "/woot", message.Header.Namespace)
+		assert.Equal(t, []interface{}{
+			json.RawMessage(`"msg"`),
+			&Buffer{IsBinary: true, Data: []byte{1, 2, 3}},
+		}, message.Data)
+	})
+}
+
+func TestEncoderDecoder(t *testing.T) {
+	var rec frameRecorder
+	packet := &Packet{
+		Header: Header{
+			Type:  Ack,
+			ID:    7,
+			HasID: true,
+		},
+		Data: []interface{}{
+			&Buffer{Data: []byte{1, 2}},
+			&Buffer{Data: []byte{3}},
+		},
+	}
+	require.NoError(t, NewEncoder(&rec).Encode(packet))
+
+	var message Packet
+	require.NoError(t, NewDecoder(&frameSource{frames: rec.frames}).Decode(&message))
+
+	assert.Equal(t, packet.Header, message.Header)
+	assert.Equal(t, []interface{}{
+		&Buffer{IsBinary: true, Num: 0, Data: []byte{1, 2}},
+		&Buffer{IsBinary: true, Num: 1, Data: []byte{3}},
+	}, message.Data)
+}
+
+func TestEncoder_broadcast(t *testing.T) {
+	packet := &Packet{
+		Header: Header{Type: Event},
+		Data:   []interface{}{"file", Buffer{Data: []byte{1, 2}}},
+	}
+
+	recs := make([]frameRecorder, 8)
+	var wg sync.WaitGroup
+	for i := range recs {
+		wg.Add(1)
+		go func(rec *frameRecorder) {
+			defer wg.Done()
+
+			enc := NewEncoder(rec)
+			for j := 0; j < 20; j++ {
+				assert.NoError(t, enc.Encode(packet))
+			}
+		}(&recs[i])
+	}
+	wg.Wait()
+
+	for _, rec := range recs {
+		require.Len(t, rec.frames, 40)
+
+		var message Packet
+		require.NoError(t, NewDecoder(&frameSource{frames: rec.frames[:2]}).Decode(&message))
+		assert.Equal(t, []interface{}{"file", &Buffer{IsBinary: true, Data: []byte{1, 2}}}, message.Data)
+	}
+	assert.Equal(t, Buffer{Data: []byte{1, 2}}, packet.Data[1])
+}
+
+func TestEncoderDecoder_stream(t *testing.T) {
+	large := bytes.Repeat([]byte{5}, 100<<10)
+	tests := []struct {
+		packet *Packet
+		data   []interface{}
+	}{
+		{
+			packet: &Packet{
+				Header: Header{Type: Event, Namespace: "/chat"},
+				Data:   []interface{}{"msg", &Buffer{Data: []byte{1, '\n', 2}}, &Buffer{Data: []byte{3}}},
+			},
+			data: []interface{}{
+				"msg",
+				&Buffer{IsBinary: true, Num: 0, Data: []byte{1, '\n', 2}},
+				&Buffer{IsBinary: true, Num: 1, Data: []byte{3}},
+			},
+		},
+		{
+			packet: &Packet{
+				Header: Header{Type: Event},
+				Data:   []interface{}{"text"},
+			},
+			data: []interface{}{"text"},
+		},
+		{
+			packet: &Packet{
+				Header: Header{Type: Ack, ID: 4, HasID: true},
+				Data:   []interface{}{&Buffer{Data: large}},
+			},
+			data: []interface{}{&Buffer{IsBinary: true, Data: large}},
+		},
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for _, test := range tests {
+		require.NoError(t, enc.Encode(test.packet))
+	}
+
+	dec := NewDecoder(&buf)
+	for _, test := range tests {
+		var message Packet
+		require.NoError(t, dec.Decode(&message))
+
+		assert.Equal(t, test.packet.Header, message.Header)
+		assert.Equal(t, test.data, message.Data)
+	}
+
+	var message Packet
+	assert.Equal(t, io.EOF, dec.Decode(&message))
+}
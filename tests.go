@@ -1,10 +1,11 @@
 package go_socketio_parser
 
 type testCase struct {
-	Name   string
-	Header Header
-	Data   []interface{} // body
-	Tmpl   string        // encoded template
+	Name    string
+	Header  Header
+	Data    []interface{} // body
+	Payload interface{}   // connect body
+	Tmpl    string        // encoded template
 }
 
 var tests = []testCase{
@@ -63,6 +64,7 @@ var tests = []testCase{
 			Type:      Event,
 			Namespace: "/admin",
 			ID:        456,
+			HasID:     true,
 		},
 		Data: []interface{}{
 			"project:delete", 123,
@@ -95,8 +97,9 @@ var tests = []testCase{
 	{
 		Name: "connect id",
 		Header: Header{
-			Type: Connect,
-			ID:   145,
+			Type:  Connect,
+			ID:    145,
+			HasID: true,
 		},
 		Tmpl: "0145",
 	},
@@ -105,7 +108,8 @@ var tests = []testCase{
 		Header: Header{
 			Type: Ack,
 
-			ID: 13,
+			ID:    13,
+			HasID: true,
 		},
 		Data: []interface{}{
 			"error",
@@ -115,8 +119,9 @@ var tests = []testCase{
 	{
 		Name: "ack id buffer",
 		Header: Header{
-			Type: Ack,
-			ID:   13,
+			Type:  Ack,
+			ID:    13,
+			HasID: true,
 		},
 		Data: []interface{}{
 			&Buffer{
@@ -165,6 +170,7 @@ var tests = []testCase{
 		Header: Header{
 			Type:      Disconnect,
 			ID:        1,
+			HasID:     true,
 			Namespace: "/woot",
 		},
 		Tmpl: "1/woot,1",
@@ -174,6 +180,7 @@ var tests = []testCase{
 		Header: Header{
 			Type:      Event,
 			ID:        1,
+			HasID:     true,
 			Namespace: "/woot",
 		},
 		Data: []interface{}{
@@ -186,6 +193,7 @@ var tests = []testCase{
 		Header: Header{
 			Type:      Event,
 			ID:        1,
+			HasID:     true,
 			Namespace: "/woot",
 		},
 		Data: []interface{}{
@@ -197,4 +205,183 @@ var tests = []testCase{
 		},
 		Tmpl: `51-/woot,1["msg",{"_placeholder":true,"num":0}]` + string('\n') + string([]byte{2, 3, 4}),
 	},
+	{
+		Name: "event id zero",
+		Header: Header{
+			Type:  Event,
+			HasID: true,
+		},
+		Data: []interface{}{
+			"msg",
+		},
+		Tmpl: `20["msg"]`,
+	},
+	{
+		Name: "ack id zero",
+		Header: Header{
+			Type:  Ack,
+			HasID: true,
+		},
+		Data: []interface{}{
+			1,
+		},
+		Tmpl: `30[1]`,
+	},
+	{
+		Name: "event nsp id zero",
+		Header: Header{
+			Type:      Event,
+			HasID:     true,
+			Namespace: "/woot",
+		},
+		Data: []interface{}{
+			"msg",
+		},
+		Tmpl: `2/woot,0["msg"]`,
+	},
+	{
+		Name: "ack id zero buffer",
+		Header: Header{
+			Type:  Ack,
+			HasID: true,
+		},
+		Data: []interface{}{
+			&Buffer{
+				IsBinary: true,
+				Data:     []byte{1, 2, 3},
+			},
+		},
+		Tmpl: `61-0[{"_placeholder":true,"num":0}]` + string('\n') + string([]byte{1, 2, 3}),
+	},
+	{
+		Name: "ack data number",
+		Header: Header{
+			Type: Ack,
+		},
+		Data: []interface{}{
+			123,
+		},
+		Tmpl: `3[123]`,
+	},
+	{
+		Name: "connect auth",
+		Header: Header{
+			Type:      Connect,
+			Namespace: "/admin",
+		},
+		Payload: map[string]interface{}{
+			"token": "123",
+		},
+		Tmpl: `0/admin,{"token":"123"}`,
+	},
+	{
+		Name: "connect sid",
+		Header: Header{
+			Type: Connect,
+		},
+		Payload: map[string]interface{}{
+			"sid": "oSO0OpakMV_3jnilAAAA",
+		},
+		Tmpl: `0{"sid":"oSO0OpakMV_3jnilAAAA"}`,
+	},
+	{
+		Name: "connect error",
+		Header: Header{
+			Type: Error,
+		},
+		Payload: &ConnectErrorPayload{
+			Message: "Not authorized",
+			Data: map[string]interface{}{
+				"code": 401,
+			},
+		},
+		Tmpl: `4{"message":"Not authorized","data":{"code":401}}`,
+	},
+	{
+		Name: "connect error nsp",
+		Header: Header{
+			Type:      Error,
+			Namespace: "/admin",
+		},
+		Payload: &ConnectErrorPayload{
+			Message: "Not authorized",
+		},
+		Tmpl: `4/admin,{"message":"Not authorized"}`,
+	},
+}
+
+// testsV4 are packets of socket.io protocol v4 (socket.io 2.x).
+var testsV4 = []testCase{
+	{
+		Name: "connect",
+		Header: Header{
+			Type: Connect,
+		},
+		Tmpl: "0",
+	},
+	{
+		Name: "connect nsp",
+		Header: Header{
+			Type:      Connect,
+			Namespace: "/admin",
+		},
+		Tmpl: "0/admin",
+	},
+	{
+		Name: "connect default nsp query",
+		Header: Header{
+			Type:  Connect,
+			Query: "token=abc",
+		},
+		Tmpl: "0/?token=abc",
+	},
+	{
+		Name: "connect query",
+		Header: Header{
+			Type:      Connect,
+			Namespace: "/admin",
+			Query:     "token=abc&v=2",
+		},
+		Tmpl: "0/admin?token=abc&v=2",
+	},
+	{
+		Name: "error",
+		Header: Header{
+			Type: Error,
+		},
+		Payload: "Not authorized",
+		Tmpl:    `4"Not authorized"`,
+	},
+	{
+		Name: "error nsp",
+		Header: Header{
+			Type:      Error,
+			Namespace: "/admin",
+		},
+		Payload: "Invalid namespace",
+		Tmpl:    `4/admin,"Invalid namespace"`,
+	},
+	{
+		Name: "error object",
+		Header: Header{
+			Type: Error,
+		},
+		Payload: map[string]interface{}{
+			"message": "Not authorized",
+		},
+		Tmpl: `4{"message":"Not authorized"}`,
+	},
+	{
+		Name: "event",
+		Header: Header{
+			Type:      Event,
+			Namespace: "/admin",
+			ID:        1,
+			HasID:     true,
+		},
+		Data: []interface{}{
+			"msg",
+		},
+		Tmpl: `2/admin,1["msg"]`,
+	},
 }
@@ -42,18 +42,43 @@ func (i Type) IsBinary() bool {
 	return i >= BinaryEvent
 }
 
+// Packet of socket.io protocol.
 type Packet struct {
 	Header Header
-	Data   []interface{}
+	// Data is the arguments array of EVENT and ACK packets.
+	Data []interface{}
+	// Payload is the object of CONNECT and CONNECT_ERROR packets, encoder
+	// rejects it with Data or in other packets. Decoder stores CONNECT object as
+	// map[string]interface{} and CONNECT_ERROR object as *ConnectErrorPayload.
+	Payload interface{}
+}
+
+// ConnectPayload is sent by server in CONNECT packet on successful connection
+// to namespace. Client sends its auth object instead.
+type ConnectPayload struct {
+	SID string `json:"sid"`
+}
+
+// ConnectErrorPayload is sent by server in CONNECT_ERROR packet when
+// connection to namespace is refused.
+type ConnectErrorPayload struct {
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
 }
 
 // Header of packet.
 type Header struct {
-	Type      Type   `json:"type"`
-	ID        uint64 `json:"id,omitempty"`
+	Type Type   `json:"type"`
+	ID   uint64 `json:"id,omitempty"`
+	// HasID marks that packet carries acknowledgment id, so zero ID is sent too.
+	// Decoder sets it for every packet with acknowledgment id.
+	HasID     bool   `json:"-"`
 	Namespace string `json:"nsp,omitempty"`
+	// Query parameters of CONNECT packet, sent inside namespace by protocol v4 only.
+	Query string `json:"-"`
 }
 
+// IsNeedAck reports whether packet carries acknowledgment id.
 func (h Header) IsNeedAck() bool {
-	return h.ID > 0
+	return h.HasID || h.ID > 0
 }
@@ -0,0 +1,164 @@
+package go_socketio_parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// maxSafeInteger is the largest integer represented exactly by JavaScript number.
+const maxSafeInteger = 1<<53 - 1
+
+// Validate checks packet by the protocol rules, the same as isPacketValid of
+// socket.io-parser:
+// * CONNECT payload is an object or absent
+// * DISCONNECT has no payload
+// * EVENT payload is a non-empty array with a number or non-reserved event name first
+// * ACK payload is an array
+// * CONNECT_ERROR payload is an object or a string
+// * acknowledgment id fits in JavaScript safe integer.
+func (p *Packet) Validate() error {
+	return p.validate(ProtocolV5)
+}
+
+// validate checks packet by the rules of the protocol version. Protocol v4
+// CONNECT has no payload and its ERROR carries any JSON value.
+func (p *Packet) validate(version ProtocolVersion) error {
+	h := p.Header
+
+	if !h.Type.IsValid() {
+		return ErrInvalidPackageType
+	}
+
+	if h.Namespace != "" && !strings.HasPrefix(h.Namespace, "/") {
+		return fmt.Errorf("%w: namespace should start with '/'", ErrInvalidPacket)
+	}
+
+	if h.IsNeedAck() && h.ID > maxSafeInteger {
+		return fmt.Errorf("%w: acknowledgment id is out of safe integer range", ErrInvalidPacket)
+	}
+
+	if h.Query != "" && (version != ProtocolV4 || h.Type != Connect) {
+		return fmt.Errorf("%w: query is sent by protocol v4 CONNECT only", ErrInvalidPacket)
+	}
+
+	switch h.Type {
+	case Connect:
+		if version == ProtocolV4 && (p.Data != nil || p.Payload != nil) {
+			return fmt.Errorf("%w: CONNECT should not have payload", ErrInvalidPacket)
+		}
+		if p.Data != nil {
+			return fmt.Errorf("%w: CONNECT should not have arguments", ErrInvalidPacket)
+		}
+		if p.Payload != nil && !isObject(p.Payload) {
+			return fmt.Errorf("%w: CONNECT payload should be an object", ErrInvalidPacket)
+		}
+	case Disconnect:
+		if p.Data != nil || p.Payload != nil {
+			return fmt.Errorf("%w: DISCONNECT should not have payload", ErrInvalidPacket)
+		}
+	case Event, BinaryEvent:
+		if p.Payload != nil {
+			return fmt.Errorf("%w: EVENT should not have object payload", ErrInvalidPacket)
+		}
+		if len(p.Data) == 0 {
+			return fmt.Errorf("%w: EVENT should have arguments", ErrInvalidPacket)
+		}
+		event := p.Data[0]
+		if raw, ok := event.(json.RawMessage); ok {
+			// argument is kept raw by zero-copy decoding.
+			event = nil
+			_ = json.Unmarshal(raw, &event)
+		}
+
+		if name, ok := event.(string); ok {
+			if isReservedEvent(name) {
+				return fmt.Errorf("%w: EVENT name %q is reserved", ErrInvalidPacket, name)
+			}
+		} else if !isNumber(event) {
+			return fmt.Errorf("%w: EVENT name should be a string or a number", ErrInvalidPacket)
+		}
+	case Ack, BinaryAck:
+		if p.Payload != nil {
+			return fmt.Errorf("%w: ACK should not have object payload", ErrInvalidPacket)
+		}
+		if p.Data == nil {
+			return fmt.Errorf("%w: ACK should have arguments", ErrInvalidPacket)
+		}
+	case Error:
+		if p.Data != nil {
+			return fmt.Errorf("%w: CONNECT_ERROR should not have arguments", ErrInvalidPacket)
+		}
+		if version == ProtocolV4 {
+			break
+		}
+		if _, ok := p.Payload.(string); !ok && !isObject(p.Payload) {
+			return fmt.Errorf("%w: CONNECT_ERROR payload should be an object or a string", ErrInvalidPacket)
+		}
+	}
+
+	return nil
+}
+
+// reservedEvents can't be sent by EVENT packet, the same as in socket.io-parser.
+var reservedEvents = []string{
+	"connect",
+	"connect_error",
+	"disconnect",
+	"disconnecting",
+	"newListener",
+	"removeListener",
+}
+
+func isReservedEvent(name string) bool {
+	for _, reserved := range reservedEvents {
+		if name == reserved {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isNumber reports whether v is encoded as JSON number.
+func isNumber(v interface{}) bool {
+	if _, ok := v.(json.Number); ok {
+		return true
+	}
+
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+// isObject reports whether v is encoded as JSON object.
+func isObject(v interface{}) bool {
+	if raw, ok := v.(json.RawMessage); ok {
+		raw = []byte(strings.TrimSpace(string(raw)))
+
+		return len(raw) > 0 && raw[0] == '{'
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return false
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Map:
+		return !rv.IsNil()
+	case reflect.Struct:
+		return true
+	}
+
+	return false
+}
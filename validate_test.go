@@ -0,0 +1,198 @@
+package go_socketio_parser
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type validateTestCase struct {
+	name   string
+	packet Packet
+	valid  bool
+}
+
+var validateTests = []validateTestCase{
+	{
+		name:   "connect",
+		packet: Packet{Header: Header{Type: Connect}},
+		valid:  true,
+	},
+	{
+		name:   "connect auth",
+		packet: Packet{Header: Header{Type: Connect, Namespace: "/admin"}, Payload: map[string]interface{}{"token": "123"}},
+		valid:  true,
+	},
+	{
+		name:   "connect sid",
+		packet: Packet{Header: Header{Type: Connect}, Payload: &ConnectPayload{SID: "abc"}},
+		valid:  true,
+	},
+	{
+		name:   "connect raw object",
+		packet: Packet{Header: Header{Type: Connect}, Payload: json.RawMessage(`{"token":"123"}`)},
+		valid:  true,
+	},
+	{
+		name:   "connect string",
+		packet: Packet{Header: Header{Type: Connect}, Payload: "token"},
+	},
+	{
+		name:   "connect array",
+		packet: Packet{Header: Header{Type: Connect}, Data: []interface{}{"token"}},
+	},
+	{
+		name:   "disconnect",
+		packet: Packet{Header: Header{Type: Disconnect, Namespace: "/woot"}},
+		valid:  true,
+	},
+	{
+		name:   "disconnect data",
+		packet: Packet{Header: Header{Type: Disconnect}, Data: []interface{}{"bye"}},
+	},
+	{
+		name:   "event",
+		packet: Packet{Header: Header{Type: Event, ID: 1}, Data: []interface{}{"msg", 1}},
+		valid:  true,
+	},
+	{
+		name:   "event without data",
+		packet: Packet{Header: Header{Type: Event}},
+	},
+	{
+		name:   "event empty data",
+		packet: Packet{Header: Header{Type: Event}, Data: []interface{}{}},
+	},
+	{
+		name:   "event number name",
+		packet: Packet{Header: Header{Type: Event}, Data: []interface{}{1, "msg"}},
+		valid:  true,
+	},
+	{
+		name:   "event object name",
+		packet: Packet{Header: Header{Type: Event}, Data: []interface{}{map[string]interface{}{}}},
+	},
+	{
+		name:   "event reserved name",
+		packet: Packet{Header: Header{Type: Event}, Data: []interface{}{"disconnect"}},
+	},
+	{
+		name:   "binary event",
+		packet: Packet{Header: Header{Type: BinaryEvent}, Data: []interface{}{"msg", &Buffer{Data: []byte{1}}}},
+		valid:  true,
+	},
+	{
+		name:   "ack",
+		packet: Packet{Header: Header{Type: Ack, HasID: true}, Data: []interface{}{}},
+		valid:  true,
+	},
+	{
+		name:   "ack without data",
+		packet: Packet{Header: Header{Type: Ack, ID: 1}},
+	},
+	{
+		name:   "ack id out of safe range",
+		packet: Packet{Header: Header{Type: Ack, ID: maxSafeInteger + 1}, Data: []interface{}{}},
+	},
+	{
+		name:   "ack max safe id",
+		packet: Packet{Header: Header{Type: Ack, ID: maxSafeInteger}, Data: []interface{}{}},
+		valid:  true,
+	},
+	{
+		name:   "connect error",
+		packet: Packet{Header: Header{Type: Error}, Payload: &ConnectErrorPayload{Message: "Not authorized"}},
+		valid:  true,
+	},
+	{
+		name:   "connect error string",
+		packet: Packet{Header: Header{Type: Error}, Payload: "Not authorized"},
+		valid:  true,
+	},
+	{
+		name:   "connect error without payload",
+		packet: Packet{Header: Header{Type: Error}},
+	},
+	{
+		name:   "connect error number",
+		packet: Packet{Header: Header{Type: Error}, Payload: 1},
+	},
+	{
+		name:   "namespace without slash",
+		packet: Packet{Header: Header{Type: Disconnect, Namespace: "woot"}},
+	},
+	{
+		name:   "invalid type",
+		packet: Packet{Header: Header{Type: BinaryAck + 1}},
+	},
+}
+
+func TestPacket_Validate(t *testing.T) {
+	for _, test := range validateTests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.packet.Validate()
+			if test.valid {
+				assert.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrInvalidPacket) || errors.Is(err, ErrInvalidPackageType))
+		})
+	}
+}
+
+func TestWithValidation(t *testing.T) {
+	t.Run("unmarshal", func(t *testing.T) {
+		var message Packet
+		require.NoError(t, Unmarshal([]byte(`2[null]`), &message))
+
+		err := Unmarshal([]byte(`2[null]`), &message, WithValidation())
+		assert.True(t, errors.Is(err, ErrInvalidPacket))
+
+		err = Unmarshal([]byte(`2/woot,1["msg"]`), &message, WithValidation())
+		assert.NoError(t, err)
+	})
+
+	t.Run("unmarshal frames", func(t *testing.T) {
+		var message Packet
+		err := UnmarshalFrames(`1["bye"]`, nil, &message, WithValidation())
+		assert.True(t, errors.Is(err, ErrInvalidPacket))
+	})
+
+	t.Run("marshal", func(t *testing.T) {
+		packet := &Packet{Header: Header{Type: Event}}
+
+		_, err := Marshal(packet)
+		require.NoError(t, err)
+
+		_, err = Marshal(packet, WithValidation())
+		assert.True(t, errors.Is(err, ErrInvalidPacket))
+
+		_, _, err = MarshalFrames(packet, WithValidation())
+		assert.True(t, errors.Is(err, ErrInvalidPacket))
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		var rec frameRecorder
+		err := NewEncoder(&rec, WithValidation()).Encode(&Packet{Header: Header{Type: Event}})
+		assert.True(t, errors.Is(err, ErrInvalidPacket))
+		assert.Empty(t, rec.frames)
+
+		dec := NewDecoder(&frameSource{
+			frames: []frame{
+				{Type: TextFrame, Data: []byte(`0["auth"]`)},
+				{Type: TextFrame, Data: []byte(`0{"token":"123"}`)},
+			},
+		}, WithValidation())
+
+		var message Packet
+		assert.True(t, errors.Is(dec.Decode(&message), ErrInvalidPacket))
+
+		require.NoError(t, dec.Decode(&message))
+		assert.Equal(t, map[string]interface{}{"token": "123"}, message.Payload)
+	})
+}